@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// arrivalMaxWait bounds how long the arrival scheduler sleeps between
+	// checks, so rate changes between stages are picked up promptly.
+	arrivalMaxWait = 10 * time.Millisecond
+	// arrivalMinWait keeps the scheduler from spinning at very high rates;
+	// arrivals that fall due in the meantime are released together.
+	arrivalMinWait = time.Millisecond
+)
+
+// runArrivalRate is the open-model executor. Iterations are started at the
+// test's target rate independently of how long the target takes to answer.
+// Each iteration borrows a worker from a pool bounded by TotalUsers; arrivals
+// that find every worker busy are counted as dropped instead of being delayed,
+// so the offered load never silently drops to what the target can sustain.
+func (tm *TestManager) runArrivalRate(testCtx *TestContext, wg *sync.WaitGroup, stopChan <-chan struct{}) {
+	defer wg.Done()
+
+	ctx := testCtx.Context
+	metrics := testCtx.Metrics
+	profile := testCtx.TestRun.LoadProfile()
+
+	// Workers share one client so their connections are pooled like a real
+	// population of clients hitting the target at a steady rate. With
+	// cookies enabled every arrival gets a client with a fresh jar instead.
+	client := newHTTPClient(testCtx)
+	cookies := testCtx.Cookies != nil && testCtx.Cookies.Enabled
+	workers := make(chan struct{}, testCtx.TestRun.TotalUsers)
+
+	start := time.Now()
+	last := start
+	lastRate := profileTarget(profile, 0)
+	pending := 0.0 // Fraction of an arrival carried over between wake-ups
+
+	timer := time.NewTimer(arrivalMinWait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopChan:
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		rate := profileTarget(profile, now.Sub(start))
+		metrics.SetTargetLoad(rate)
+
+		// Integrate the (possibly ramping) rate over the time since the last
+		// wake-up to find how many arrivals became due.
+		due := (lastRate + rate) / 2 * now.Sub(last).Seconds()
+		carried := pending
+		pending += due
+		for n := 1.0; pending >= 1; n, pending = n+1, pending-1 {
+			// Place each arrival where it fell due between the two wake-ups,
+			// so scheduler lag shows up in its corrected latency.
+			intended := now
+			if due > 0 {
+				fraction := math.Min(math.Max((n-carried)/due, 0), 1)
+				intended = last.Add(time.Duration(fraction * float64(now.Sub(last))))
+			}
+
+			select {
+			case workers <- struct{}{}:
+				wg.Add(1)
+				atomic.AddInt64(&metrics.ActiveUsers, 1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-workers }()
+					defer atomic.AddInt64(&metrics.ActiveUsers, -1)
+					// Each arrival is a new virtual user with its own variables
+					// and cookies
+					userClient := client
+					if cookies {
+						userClient = newHTTPClient(testCtx)
+					}
+					user := newVirtualUser(testCtx, false)
+					tm.runIteration(ctx, userClient, testCtx, user, intended, stopChan, nil)
+					user.closeSocket()
+				}()
+			default:
+				metrics.RecordDropped()
+			}
+		}
+		last, lastRate = now, rate
+
+		wait := arrivalMaxWait
+		if rate > 0 {
+			if untilNext := time.Duration((1 - pending) / rate * float64(time.Second)); untilNext < wait {
+				wait = untilNext
+			}
+		}
+		if wait < arrivalMinWait {
+			wait = arrivalMinWait
+		}
+		timer.Reset(wait)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Assertion types.
+const (
+	AssertionStatus          = "status"            // Status code is in Value, e.g. "200,201" or "2xx" or "200-204"
+	AssertionBodyContains    = "body_contains"     // Body contains Value
+	AssertionBodyRegex       = "body_regex"        // Body matches the regular expression in Value
+	AssertionJSON            = "json"              // JSONPath Target exists, and equals Value if set
+	AssertionHeader          = "header"            // Header Target is present, and equals Value if set
+	AssertionMaxResponseTime = "max_response_time" // Latency is at most Max milliseconds
+	AssertionMaxBodySize     = "max_body_size"     // Body is at most Max bytes
+)
+
+const MaxAssertionsPerStep = 10
+
+// Assertion is a check a response must pass to count as a success. A failed
+// assertion is recorded as an error with the assertion's name as its
+// category.
+type Assertion struct {
+	Name   string `json:"name,omitempty"` // Error category on failure (default: "assert_" + type)
+	Type   string `json:"type"`
+	Target string `json:"target,omitempty"` // JSONPath or header name
+	Value  string `json:"value,omitempty"`  // Expected statuses, substring, regex or value
+	Max    int64  `json:"max,omitempty"`    // Limit for max_response_time and max_body_size
+
+	statuses []statusRange
+	regex    *regexp.Regexp
+	jsonPath []jsonPathSegment
+}
+
+type statusRange struct {
+	low, high int
+}
+
+// validateAssertions checks a step's assertions, fills in default names and
+// compiles their expressions.
+func validateAssertions(assertions []Assertion) error {
+	if len(assertions) > MaxAssertionsPerStep {
+		return fmt.Errorf("at most %d assertions are allowed", MaxAssertionsPerStep)
+	}
+	for i := range assertions {
+		assertion := &assertions[i]
+		if assertion.Name == "" {
+			assertion.Name = "assert_" + assertion.Type
+		}
+		if len(assertion.Name) > maxVariableNameLen || !variableNamePattern.MatchString(assertion.Name) {
+			return fmt.Errorf("assertion %d: name must be a letter or underscore followed by letters, digits or underscores", i+1)
+		}
+
+		switch assertion.Type {
+		case AssertionStatus:
+			statuses, err := parseStatusRanges(assertion.Value)
+			if err != nil {
+				return fmt.Errorf("assertion %q: %v", assertion.Name, err)
+			}
+			assertion.statuses = statuses
+		case AssertionBodyContains:
+			if assertion.Value == "" {
+				return fmt.Errorf("assertion %q: value is required", assertion.Name)
+			}
+		case AssertionBodyRegex:
+			re, err := regexp.Compile(assertion.Value)
+			if err != nil {
+				return fmt.Errorf("assertion %q: invalid regex: %v", assertion.Name, err)
+			}
+			assertion.regex = re
+		case AssertionJSON:
+			path, err := parseJSONPath(assertion.Target)
+			if err != nil {
+				return fmt.Errorf("assertion %q: %v", assertion.Name, err)
+			}
+			assertion.jsonPath = path
+		case AssertionHeader:
+			if assertion.Target == "" {
+				return fmt.Errorf("assertion %q: target header is required", assertion.Name)
+			}
+			assertion.Target = http.CanonicalHeaderKey(assertion.Target)
+		case AssertionMaxResponseTime, AssertionMaxBodySize:
+			if assertion.Max <= 0 {
+				return fmt.Errorf("assertion %q: max must be greater than 0", assertion.Name)
+			}
+		default:
+			return fmt.Errorf("assertion %q: type must be one of %v", assertion.Name, []string{
+				AssertionStatus, AssertionBodyContains, AssertionBodyRegex, AssertionJSON,
+				AssertionHeader, AssertionMaxResponseTime, AssertionMaxBodySize,
+			})
+		}
+	}
+	return nil
+}
+
+// parseStatusRanges parses a comma-separated list of status codes, classes
+// such as 2xx and ranges such as 200-299.
+func parseStatusRanges(value string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, part := range strings.Split(value, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		var r statusRange
+		switch {
+		case len(part) == 3 && strings.HasSuffix(part, "xx") && part[0] >= '1' && part[0] <= '5':
+			r.low = int(part[0]-'0') * 100
+			r.high = r.low + 99
+		case strings.Contains(part, "-"):
+			low, high, _ := strings.Cut(part, "-")
+			var errLow, errHigh error
+			r.low, errLow = strconv.Atoi(strings.TrimSpace(low))
+			r.high, errHigh = strconv.Atoi(strings.TrimSpace(high))
+			if errLow != nil || errHigh != nil {
+				return nil, fmt.Errorf("invalid status range %q", part)
+			}
+		default:
+			code, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid status %q", part)
+			}
+			r.low, r.high = code, code
+		}
+		if r.low < 100 || r.high > 599 || r.low > r.high {
+			return nil, fmt.Errorf("status %q must be within 100-599", part)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// stepAssertsStatus reports whether a step replaces the default success rule
+// (status below 400) with its own status assertion.
+func stepAssertsStatus(step *ScenarioStep) bool {
+	for _, assertion := range step.Assertions {
+		if assertion.Type == AssertionStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAssertions returns the first assertion the response fails, with a
+// message describing the failure. body holds the captured start of the
+// response body and bodySize its full length.
+func checkAssertions(step *ScenarioStep, resp *http.Response, body []byte, bodySize int64, latency float64) (*Assertion, string) {
+	var doc interface{}
+	decoded := false
+	for i := range step.Assertions {
+		assertion := &step.Assertions[i]
+		switch assertion.Type {
+		case AssertionStatus:
+			matched := false
+			for _, r := range assertion.statuses {
+				if resp.StatusCode >= r.low && resp.StatusCode <= r.high {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return assertion, fmt.Sprintf("status %d not in %s", resp.StatusCode, assertion.Value)
+			}
+		case AssertionBodyContains:
+			if !bytes.Contains(body, []byte(assertion.Value)) {
+				return assertion, fmt.Sprintf("body does not contain %q", assertion.Value)
+			}
+		case AssertionBodyRegex:
+			if !assertion.regex.Match(body) {
+				return assertion, fmt.Sprintf("body does not match %q", assertion.Value)
+			}
+		case AssertionJSON:
+			if !decoded {
+				decoded = true
+				if err := json.Unmarshal(body, &doc); err != nil {
+					doc = nil
+				}
+			}
+			if doc == nil {
+				return assertion, "body is not valid JSON"
+			}
+			raw, found := lookupJSONPath(doc, assertion.jsonPath)
+			if !found {
+				return assertion, fmt.Sprintf("%s not found", assertion.Target)
+			}
+			if assertion.Value != "" {
+				if got := jsonValueString(raw); got != assertion.Value {
+					return assertion, fmt.Sprintf("%s is %q, expected %q", assertion.Target, got, assertion.Value)
+				}
+			}
+		case AssertionHeader:
+			values := resp.Header.Values(assertion.Target)
+			if len(values) == 0 {
+				return assertion, fmt.Sprintf("header %s missing", assertion.Target)
+			}
+			if assertion.Value != "" && values[0] != assertion.Value {
+				return assertion, fmt.Sprintf("header %s is %q, expected %q", assertion.Target, values[0], assertion.Value)
+			}
+		case AssertionMaxResponseTime:
+			if latency > float64(assertion.Max) {
+				return assertion, fmt.Sprintf("response time %.2f ms exceeds %d ms", latency, assertion.Max)
+			}
+		case AssertionMaxBodySize:
+			if bodySize > assertion.Max {
+				return assertion, fmt.Sprintf("body size %d bytes exceeds %d bytes", bodySize, assertion.Max)
+			}
+		}
+	}
+	return nil, ""
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const MaxClientTLSBytes = 256 << 10 // Combined size of a test's certificate, key and CA bundle
+
+// ClientTLSConfig is the client certificate sent to /api/start, in PEM.
+type ClientTLSConfig struct {
+	Cert string `json:"cert,omitempty"` // Certificate, followed by any intermediates
+	Key  string `json:"key,omitempty"`  // Unencrypted private key
+	CA   string `json:"ca,omitempty"`   // Bundle trusted instead of the system roots
+}
+
+// TLSSummary describes a test's client certificate and CA bundle. The PEM
+// itself is only held in memory while the test runs.
+type TLSSummary struct {
+	ClientCert bool       `json:"client_cert"`
+	Subject    string     `json:"subject,omitempty"`
+	Issuer     string     `json:"issuer,omitempty"`
+	NotAfter   *time.Time `json:"not_after,omitempty"`
+	CACerts    int        `json:"ca_certs,omitempty"` // Certificates in the custom CA bundle
+}
+
+// TLSSession counts the handshakes that negotiated one protocol version and
+// cipher suite.
+type TLSSession struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+	Handshakes  int64  `json:"handshakes"`
+}
+
+type tlsSessionKey struct {
+	version, cipherSuite uint16
+}
+
+// clientTLS holds the parsed credentials a test's transport presents and
+// trusts.
+type clientTLS struct {
+	certificate *tls.Certificate
+	rootCAs     *x509.CertPool
+}
+
+// parseClientTLS checks that the certificate matches the key and that the CA
+// bundle holds at least one certificate.
+func parseClientTLS(cfg *ClientTLSConfig) (*clientTLS, *TLSSummary, error) {
+	if len(cfg.Cert)+len(cfg.Key)+len(cfg.CA) > MaxClientTLSBytes {
+		return nil, nil, fmt.Errorf("certificate, key and ca must be at most %d bytes together", MaxClientTLSBytes)
+	}
+	if cfg.Cert == "" && cfg.Key == "" && cfg.CA == "" {
+		return nil, nil, fmt.Errorf("cert and key, or ca, are required")
+	}
+	if (cfg.Cert == "") != (cfg.Key == "") {
+		return nil, nil, fmt.Errorf("cert and key must be given together")
+	}
+
+	creds := &clientTLS{}
+	summary := &TLSSummary{}
+	if cfg.Cert != "" {
+		certificate, err := tls.X509KeyPair([]byte(cfg.Cert), []byte(cfg.Key))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		creds.certificate = &certificate
+		notAfter := leaf.NotAfter.UTC()
+		summary.ClientCert = true
+		summary.Subject = leaf.Subject.String()
+		summary.Issuer = leaf.Issuer.String()
+		summary.NotAfter = &notAfter
+	}
+	if cfg.CA != "" {
+		creds.rootCAs = x509.NewCertPool()
+		rest := []byte(cfg.CA)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid ca certificate: %v", err)
+			}
+			creds.rootCAs.AddCert(cert)
+			summary.CACerts++
+		}
+		if summary.CACerts == 0 {
+			return nil, nil, fmt.Errorf("ca has no PEM certificates")
+		}
+	}
+	return creds, summary, nil
+}
+
+// apply sets the certificate and trusted CAs on a transport's TLS config.
+func (creds *clientTLS) apply(config *tls.Config) {
+	if creds == nil {
+		return
+	}
+	if creds.certificate != nil {
+		config.Certificates = []tls.Certificate{*creds.certificate}
+	}
+	config.RootCAs = creds.rootCAs
+}
+
+// RecordTLSSession counts a completed TLS handshake.
+func (mc *MetricsCollector) RecordTLSSession(version, cipherSuite uint16) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.tlsSessions == nil {
+		mc.tlsSessions = make(map[tlsSessionKey]int64)
+	}
+	mc.tlsSessions[tlsSessionKey{version, cipherSuite}]++
+}
+
+// TLSSessions returns the negotiated versions and cipher suites, most used
+// first, or nil when no TLS connection was made.
+func (mc *MetricsCollector) TLSSessions() []TLSSession {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	var sessions []TLSSession
+	for key, handshakes := range mc.tlsSessions {
+		sessions = append(sessions, TLSSession{
+			Version:     tls.VersionName(key.version),
+			CipherSuite: tls.CipherSuiteName(key.cipherSuite),
+			Handshakes:  handshakes,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Handshakes != sessions[j].Handshakes {
+			return sessions[i].Handshakes > sessions[j].Handshakes
+		}
+		return sessions[i].Version+sessions[i].CipherSuite < sessions[j].Version+sessions[j].CipherSuite
+	})
+	return sessions
+}
@@ -40,14 +40,53 @@ type TestRun struct {
 	MaxConcurrentRequests int               `json:"max_concurrent_requests,omitempty"`
 	ErrorThreshold        float64           `json:"error_threshold,omitempty"`
 	StoppedByCircuit      bool              `json:"stopped_by_circuit,omitempty"`
+	Executor              string            `json:"executor"`
+	TargetRPS             float64           `json:"target_rps,omitempty"`
+	DroppedIterations     int64             `json:"dropped_iterations"`
+	Stages                []Stage           `json:"stages,omitempty"`
+	CorrectedAvgLatency   float64           `json:"corrected_avg_latency"`
+	CorrectedMaxLatency   float64           `json:"corrected_max_latency"`
+	HistogramPrecision    int               `json:"histogram_precision"`
+	PhaseBreakdown        PhaseTimings      `json:"phase_breakdown"`
+	ErrorBreakdown        *ErrorBreakdown   `json:"error_breakdown,omitempty"`
+	StatusCodes           map[int]int64     `json:"status_codes,omitempty"` // Requests per status code, 0 for no response
+	DroppedSamples        int64             `json:"dropped_samples"`        // Request metrics that could not be persisted
+	Transport             TransportConfig   `json:"transport"`
+	Scenario              []ScenarioStep    `json:"scenario,omitempty"`
+	RequestMix            []ScenarioStep    `json:"request_mix,omitempty"` // Weighted requests, one picked per iteration
+	Assertions            []Assertion       `json:"assertions,omitempty"`  // Success checks of a single-request test
+	Data                  *DataSummary      `json:"data,omitempty"`        // Data file feeding the test's templates
+	ThinkTime             *ThinkTime        `json:"think_time,omitempty"`  // Pause after each request unless its step sets one
+	PacingMs              int               `json:"pacing_ms,omitempty"`   // Fixed iteration duration for closed-loop users
+	Cookies               *CookieConfig     `json:"cookies,omitempty"`     // Cookie jar settings, without seed values
+	Redirects             RedirectConfig    `json:"redirects"`
+	SessionsEstablished   int64             `json:"sessions_established"`    // Users whose cookie jar received a cookie
+	TokenMetrics          *TokenMetrics     `json:"token_metrics,omitempty"` // OAuth2 token endpoint requests
+	TLS                   *TLSSummary       `json:"tls,omitempty"`           // Client certificate and CA bundle, without the PEM
+	TLSSessions           []TLSSession      `json:"tls_sessions,omitempty"`  // Negotiated TLS versions and cipher suites
+	WebSocket             *WebSocketConfig  `json:"websocket,omitempty"`     // Connection and message settings of a WebSocket test
+	WebSocketMetrics      *WebSocketMetrics `json:"websocket_metrics,omitempty"`
+	GRPC                  *GRPCConfig       `json:"grpc,omitempty"` // Method and payload of a gRPC test, without the descriptor set
+	GRPCMetrics           *GRPCMetrics      `json:"grpc_metrics,omitempty"`
+	GraphQL               *GraphQLRequest   `json:"graphql,omitempty"` // GraphQL operation of a single-request test; scenario steps carry their own
+	GraphQLOperations     []GraphQLMetrics  `json:"graphql_operations,omitempty"`
+	Stream                *StreamConfig     `json:"stream,omitempty"` // Format and limits of a stream test
+	StreamMetrics         *StreamMetrics    `json:"stream_metrics,omitempty"`
+	Socket                *SocketConfig     `json:"socket,omitempty"` // Protocol, payload and response framing of a socket test
+	SocketMetrics         *SocketMetrics    `json:"socket_metrics,omitempty"`
+	StepMetrics           []StepMetrics     `json:"step_metrics,omitempty"` // Per step of a scenario or per request of a mix
 }
 
 type RequestMetric struct {
-	TestRunID  int64
-	Timestamp  time.Time
-	Latency    float64
-	Success    bool
-	StatusCode int
+	TestRunID        int64
+	Timestamp        time.Time
+	Latency          float64 // Service time in milliseconds
+	CorrectedLatency float64 // Milliseconds since the intended send time
+	Success          bool
+	StatusCode       int
+	ErrorCategory    string       // Empty for successful requests
+	Phases           PhaseTimings // Zero when no response was received
+	GRPCStatus       string       // Status code name of a gRPC call, in place of StatusCode
 }
 
 func InitDB() (*sql.DB, error) {
@@ -108,7 +147,44 @@ func InitDB() (*sql.DB, error) {
 		rps REAL DEFAULT 0,
 		method TEXT DEFAULT 'GET',
 		body TEXT,
-		headers TEXT
+		headers TEXT,
+		executor TEXT NOT NULL DEFAULT 'closed_loop',
+		target_rps REAL DEFAULT 0,
+		dropped_iterations INTEGER DEFAULT 0,
+		stages TEXT,
+		corrected_avg_latency REAL DEFAULT 0,
+		corrected_max_latency REAL DEFAULT 0,
+		histogram_precision INTEGER DEFAULT 3,
+		latency_histogram TEXT,
+		corrected_latency_histogram TEXT,
+		phase_breakdown TEXT,
+		error_breakdown TEXT,
+		status_codes TEXT,
+		dropped_samples INTEGER DEFAULT 0,
+		transport TEXT,
+		scenario TEXT,
+		step_metrics TEXT,
+		request_mix TEXT,
+		assertions TEXT,
+		data TEXT,
+		think_time TEXT,
+		pacing_ms INTEGER DEFAULT 0,
+		cookies TEXT,
+		redirects TEXT,
+		sessions_established INTEGER DEFAULT 0,
+		token_metrics TEXT,
+		tls TEXT,
+		tls_sessions TEXT,
+		websocket TEXT,
+		websocket_metrics TEXT,
+		grpc TEXT,
+		grpc_metrics TEXT,
+		graphql TEXT,
+		graphql_operations TEXT,
+		stream TEXT,
+		stream_metrics TEXT,
+		socket TEXT,
+		socket_metrics TEXT
 	);
 
 	CREATE TABLE IF NOT EXISTS request_metrics (
@@ -116,8 +192,16 @@ func InitDB() (*sql.DB, error) {
 		test_run_id INTEGER NOT NULL,
 		timestamp DATETIME NOT NULL,
 		latency REAL NOT NULL,
+		corrected_latency REAL,
 		success INTEGER NOT NULL,
 		status_code INTEGER NOT NULL,
+		dns_ms REAL,
+		connect_ms REAL,
+		tls_ms REAL,
+		ttfb_ms REAL,
+		download_ms REAL,
+		error_category TEXT,
+		grpc_status TEXT,
 		FOREIGN KEY (test_run_id) REFERENCES test_runs(id)
 	);
 
@@ -252,11 +336,143 @@ func SaveTestRun(db *sql.DB, testRun *TestRun) (int64, error) {
 		headersJSON = string(headersBytes)
 	}
 
+	var stagesJSON string
+	if len(testRun.Stages) > 0 {
+		stagesBytes, err := json.Marshal(testRun.Stages)
+		if err != nil {
+			return 0, err
+		}
+		stagesJSON = string(stagesBytes)
+	}
+
+	transportJSON, err := json.Marshal(testRun.Transport)
+	if err != nil {
+		return 0, err
+	}
+
+	var scenarioJSON string
+	if len(testRun.Scenario) > 0 {
+		scenarioBytes, err := json.Marshal(testRun.Scenario)
+		if err != nil {
+			return 0, err
+		}
+		scenarioJSON = string(scenarioBytes)
+	}
+
+	var mixJSON string
+	if len(testRun.RequestMix) > 0 {
+		mixBytes, err := json.Marshal(testRun.RequestMix)
+		if err != nil {
+			return 0, err
+		}
+		mixJSON = string(mixBytes)
+	}
+
+	var assertionsJSON string
+	if len(testRun.Assertions) > 0 {
+		assertionBytes, err := json.Marshal(testRun.Assertions)
+		if err != nil {
+			return 0, err
+		}
+		assertionsJSON = string(assertionBytes)
+	}
+
+	var dataJSON string
+	if testRun.Data != nil {
+		dataBytes, err := json.Marshal(testRun.Data)
+		if err != nil {
+			return 0, err
+		}
+		dataJSON = string(dataBytes)
+	}
+
+	var thinkTimeJSON string
+	if testRun.ThinkTime != nil {
+		thinkTimeBytes, err := json.Marshal(testRun.ThinkTime)
+		if err != nil {
+			return 0, err
+		}
+		thinkTimeJSON = string(thinkTimeBytes)
+	}
+
+	var cookiesJSON string
+	if testRun.Cookies != nil {
+		cookieBytes, err := json.Marshal(testRun.Cookies)
+		if err != nil {
+			return 0, err
+		}
+		cookiesJSON = string(cookieBytes)
+	}
+
+	redirectsJSON, err := json.Marshal(testRun.Redirects)
+	if err != nil {
+		return 0, err
+	}
+
+	var tlsJSON string
+	if testRun.TLS != nil {
+		tlsBytes, err := json.Marshal(testRun.TLS)
+		if err != nil {
+			return 0, err
+		}
+		tlsJSON = string(tlsBytes)
+	}
+
+	var webSocketJSON string
+	if testRun.WebSocket != nil {
+		webSocketBytes, err := json.Marshal(testRun.WebSocket)
+		if err != nil {
+			return 0, err
+		}
+		webSocketJSON = string(webSocketBytes)
+	}
+
+	var grpcJSON string
+	if testRun.GRPC != nil {
+		grpcBytes, err := json.Marshal(testRun.GRPC)
+		if err != nil {
+			return 0, err
+		}
+		grpcJSON = string(grpcBytes)
+	}
+
+	var graphQLJSON string
+	if testRun.GraphQL != nil {
+		graphQLBytes, err := json.Marshal(testRun.GraphQL)
+		if err != nil {
+			return 0, err
+		}
+		graphQLJSON = string(graphQLBytes)
+	}
+
+	var streamJSON string
+	if testRun.Stream != nil {
+		streamBytes, err := json.Marshal(testRun.Stream)
+		if err != nil {
+			return 0, err
+		}
+		streamJSON = string(streamBytes)
+	}
+
+	var socketJSON string
+	if testRun.Socket != nil {
+		socketBytes, err := json.Marshal(testRun.Socket)
+		if err != nil {
+			return 0, err
+		}
+		socketJSON = string(socketBytes)
+	}
+
 	result, err := db.Exec(
-		`INSERT INTO test_runs (uuid, host, mask_host, total_users, ramp_up_sec, duration, status, started_at, method, body, headers)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO test_runs (uuid, host, mask_host, total_users, ramp_up_sec, duration, status, started_at, method, body, headers,
+		 executor, target_rps, stages, histogram_precision, transport, scenario, request_mix, assertions, data,
+		 think_time, pacing_ms, cookies, redirects, tls, websocket, grpc, graphql, stream, socket)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		testRun.UUID, testRun.Host, testRun.MaskHost, testRun.TotalUsers, testRun.RampUpSec, testRun.Duration, testRun.Status, testRun.StartedAt,
-		testRun.Method, testRun.Body, headersJSON,
+		testRun.Method, testRun.Body, headersJSON, testRun.Executor, testRun.TargetRPS, stagesJSON, testRun.HistogramPrecision,
+		string(transportJSON), scenarioJSON, mixJSON, assertionsJSON, dataJSON,
+		thinkTimeJSON, testRun.PacingMs, cookiesJSON, string(redirectsJSON), tlsJSON, webSocketJSON, grpcJSON, graphQLJSON,
+		streamJSON, socketJSON,
 	)
 	if err != nil {
 		return 0, err
@@ -265,35 +481,180 @@ func SaveTestRun(db *sql.DB, testRun *TestRun) (int64, error) {
 }
 
 func UpdateTestRun(db *sql.DB, testRun *TestRun) error {
-	_, err := db.Exec(
+	phasesJSON, err := json.Marshal(testRun.PhaseBreakdown)
+	if err != nil {
+		return err
+	}
+
+	var errorsJSON sql.NullString
+	if testRun.ErrorBreakdown != nil {
+		errorBytes, err := json.Marshal(testRun.ErrorBreakdown)
+		if err != nil {
+			return err
+		}
+		errorsJSON = sql.NullString{String: string(errorBytes), Valid: true}
+	}
+
+	var stepsJSON sql.NullString
+	if len(testRun.StepMetrics) > 0 {
+		stepBytes, err := json.Marshal(testRun.StepMetrics)
+		if err != nil {
+			return err
+		}
+		stepsJSON = sql.NullString{String: string(stepBytes), Valid: true}
+	}
+
+	var tokenJSON sql.NullString
+	if testRun.TokenMetrics != nil {
+		tokenBytes, err := json.Marshal(testRun.TokenMetrics)
+		if err != nil {
+			return err
+		}
+		tokenJSON = sql.NullString{String: string(tokenBytes), Valid: true}
+	}
+
+	var tlsSessionsJSON sql.NullString
+	if len(testRun.TLSSessions) > 0 {
+		sessionBytes, err := json.Marshal(testRun.TLSSessions)
+		if err != nil {
+			return err
+		}
+		tlsSessionsJSON = sql.NullString{String: string(sessionBytes), Valid: true}
+	}
+
+	var webSocketJSON sql.NullString
+	if testRun.WebSocketMetrics != nil {
+		webSocketBytes, err := json.Marshal(testRun.WebSocketMetrics)
+		if err != nil {
+			return err
+		}
+		webSocketJSON = sql.NullString{String: string(webSocketBytes), Valid: true}
+	}
+
+	var grpcJSON sql.NullString
+	if testRun.GRPCMetrics != nil {
+		grpcBytes, err := json.Marshal(testRun.GRPCMetrics)
+		if err != nil {
+			return err
+		}
+		grpcJSON = sql.NullString{String: string(grpcBytes), Valid: true}
+	}
+
+	var graphQLJSON sql.NullString
+	if len(testRun.GraphQLOperations) > 0 {
+		graphQLBytes, err := json.Marshal(testRun.GraphQLOperations)
+		if err != nil {
+			return err
+		}
+		graphQLJSON = sql.NullString{String: string(graphQLBytes), Valid: true}
+	}
+
+	var streamJSON sql.NullString
+	if testRun.StreamMetrics != nil {
+		streamBytes, err := json.Marshal(testRun.StreamMetrics)
+		if err != nil {
+			return err
+		}
+		streamJSON = sql.NullString{String: string(streamBytes), Valid: true}
+	}
+
+	var socketJSON sql.NullString
+	if testRun.SocketMetrics != nil {
+		socketBytes, err := json.Marshal(testRun.SocketMetrics)
+		if err != nil {
+			return err
+		}
+		socketJSON = sql.NullString{String: string(socketBytes), Valid: true}
+	}
+
+	var statusCodesJSON sql.NullString
+	if testRun.StatusCodes != nil {
+		statusBytes, err := json.Marshal(testRun.StatusCodes)
+		if err != nil {
+			return err
+		}
+		statusCodesJSON = sql.NullString{String: string(statusBytes), Valid: true}
+	}
+
+	_, err = db.Exec(
 		`UPDATE test_runs SET
 		 status = ?, completed_at = ?, total_requests = ?, success_count = ?, error_count = ?,
-		 avg_latency = ?, min_latency = ?, max_latency = ?, rps = ?
+		 avg_latency = ?, min_latency = ?, max_latency = ?, rps = ?, dropped_iterations = ?,
+		 corrected_avg_latency = ?, corrected_max_latency = ?, phase_breakdown = ?, error_breakdown = ?,
+		 status_codes = ?, dropped_samples = ?, step_metrics = ?, sessions_established = ?,
+		 token_metrics = ?, tls_sessions = ?, websocket_metrics = ?, grpc_metrics = ?, graphql_operations = ?,
+		 stream_metrics = ?, socket_metrics = ?
 		 WHERE id = ?`,
 		testRun.Status, testRun.CompletedAt, testRun.TotalRequests, testRun.SuccessCount, testRun.ErrorCount,
-		testRun.AvgLatency, testRun.MinLatency, testRun.MaxLatency, testRun.RPS, testRun.ID,
+		testRun.AvgLatency, testRun.MinLatency, testRun.MaxLatency, testRun.RPS, testRun.DroppedIterations,
+		testRun.CorrectedAvgLatency, testRun.CorrectedMaxLatency, string(phasesJSON), errorsJSON,
+		statusCodesJSON, testRun.DroppedSamples, stepsJSON, testRun.SessionsEstablished, tokenJSON,
+		tlsSessionsJSON, webSocketJSON, grpcJSON, graphQLJSON, streamJSON, socketJSON, testRun.ID,
 	)
 	return err
 }
 
-func GetTestRun(db *sql.DB, id int64) (*TestRun, error) {
+// SaveLatencyHistograms stores the serialized service and corrected latency
+// histograms of a finished test.
+func SaveLatencyHistograms(db *sql.DB, testRunID int64, service, corrected string) error {
+	_, err := db.Exec(
+		`UPDATE test_runs SET latency_histogram = ?, corrected_latency_histogram = ? WHERE id = ?`,
+		service, corrected, testRunID,
+	)
+	return err
+}
+
+// GetLatencyHistograms returns the serialized latency histograms of a test.
+// Both are empty for tests recorded before histograms were persisted.
+func GetLatencyHistograms(db *sql.DB, testRunID int64) (service, corrected string, err error) {
+	var serviceHist, correctedHist sql.NullString
+	err = db.QueryRow(
+		`SELECT latency_histogram, corrected_latency_histogram FROM test_runs WHERE id = ?`,
+		testRunID,
+	).Scan(&serviceHist, &correctedHist)
+	if err != nil {
+		return "", "", err
+	}
+	return serviceHist.String, correctedHist.String, nil
+}
+
+// testRunColumns lists the test_runs columns read by scanTestRun, in order.
+const testRunColumns = `id, uuid, host, mask_host, total_users, ramp_up_sec, duration, status, started_at, completed_at,
+		 total_requests, success_count, error_count, avg_latency, min_latency, max_latency, rps,
+		 method, body, headers, executor, target_rps, dropped_iterations, stages,
+		 corrected_avg_latency, corrected_max_latency, histogram_precision, phase_breakdown,
+		 error_breakdown, status_codes, dropped_samples, transport, scenario, step_metrics,
+		 request_mix, assertions, data, think_time, pacing_ms, cookies, redirects, sessions_established,
+		 token_metrics, tls, tls_sessions, websocket, websocket_metrics, grpc, grpc_metrics,
+		 graphql, graphql_operations, stream, stream_metrics, socket, socket_metrics`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTestRun(row rowScanner) (*TestRun, error) {
 	var testRun TestRun
 	var completedAt sql.NullTime
-	var method, body, headersJSON sql.NullString
+	var method, body, headersJSON, executor, stagesJSON, phasesJSON, errorsJSON, statusCodesJSON, transportJSON sql.NullString
+	var scenarioJSON, stepsJSON, mixJSON, assertionsJSON, dataJSON, thinkTimeJSON, cookiesJSON, redirectsJSON, tokenJSON sql.NullString
+	var tlsJSON, tlsSessionsJSON, webSocketJSON, webSocketMetricsJSON, grpcJSON, grpcMetricsJSON sql.NullString
+	var graphQLJSON, graphQLOperationsJSON, streamJSON, streamMetricsJSON, socketJSON, socketMetricsJSON sql.NullString
 	var maskHost sql.NullBool
+	var targetRPS, correctedAvg, correctedMax sql.NullFloat64
+	var droppedIterations, histogramPrecision, droppedSamples, pacingMs, sessions sql.NullInt64
 
-	err := db.QueryRow(
-		`SELECT id, uuid, host, mask_host, total_users, ramp_up_sec, duration, status, started_at, completed_at,
-		 total_requests, success_count, error_count, avg_latency, min_latency, max_latency, rps,
-		 method, body, headers
-		 FROM test_runs WHERE id = ?`,
-		id,
-	).Scan(
+	err := row.Scan(
 		&testRun.ID, &testRun.UUID, &testRun.Host, &maskHost, &testRun.TotalUsers, &testRun.RampUpSec, &testRun.Duration,
 		&testRun.Status, &testRun.StartedAt, &completedAt,
 		&testRun.TotalRequests, &testRun.SuccessCount, &testRun.ErrorCount,
 		&testRun.AvgLatency, &testRun.MinLatency, &testRun.MaxLatency, &testRun.RPS,
-		&method, &body, &headersJSON,
+		&method, &body, &headersJSON, &executor, &targetRPS, &droppedIterations, &stagesJSON,
+		&correctedAvg, &correctedMax, &histogramPrecision, &phasesJSON,
+		&errorsJSON, &statusCodesJSON, &droppedSamples, &transportJSON, &scenarioJSON, &stepsJSON,
+		&mixJSON, &assertionsJSON, &dataJSON, &thinkTimeJSON, &pacingMs,
+		&cookiesJSON, &redirectsJSON, &sessions, &tokenJSON, &tlsJSON, &tlsSessionsJSON,
+		&webSocketJSON, &webSocketMetricsJSON, &grpcJSON, &grpcMetricsJSON, &graphQLJSON, &graphQLOperationsJSON,
+		&streamJSON, &streamMetricsJSON, &socketJSON, &socketMetricsJSON,
 	)
 	if err != nil {
 		return nil, err
@@ -320,63 +681,221 @@ func GetTestRun(db *sql.DB, id int64) (*TestRun, error) {
 	} else {
 		testRun.MaskHost = true
 	}
+	if executor.Valid && executor.String != "" {
+		testRun.Executor = executor.String
+	} else {
+		testRun.Executor = ExecutorClosedLoop
+	}
+	if targetRPS.Valid {
+		testRun.TargetRPS = targetRPS.Float64
+	}
+	if droppedIterations.Valid {
+		testRun.DroppedIterations = droppedIterations.Int64
+	}
+	if droppedSamples.Valid {
+		testRun.DroppedSamples = droppedSamples.Int64
+	}
+	if correctedAvg.Valid {
+		testRun.CorrectedAvgLatency = correctedAvg.Float64
+	}
+	if correctedMax.Valid {
+		testRun.CorrectedMaxLatency = correctedMax.Float64
+	}
+	if histogramPrecision.Valid && histogramPrecision.Int64 > 0 {
+		testRun.HistogramPrecision = int(histogramPrecision.Int64)
+	} else {
+		testRun.HistogramPrecision = DefaultHistogramPrecision
+	}
+	if stagesJSON.Valid && stagesJSON.String != "" {
+		var stages []Stage
+		if err := json.Unmarshal([]byte(stagesJSON.String), &stages); err == nil {
+			testRun.Stages = stages
+		}
+	}
 
-	return &testRun, nil
-}
+	if phasesJSON.Valid && phasesJSON.String != "" {
+		var phases PhaseTimings
+		if err := json.Unmarshal([]byte(phasesJSON.String), &phases); err == nil {
+			testRun.PhaseBreakdown = phases
+		}
+	}
 
-func GetTestRunByUUID(db *sql.DB, uuid string) (*TestRun, error) {
-	var testRun TestRun
-	var completedAt sql.NullTime
-	var method, body, headersJSON sql.NullString
-	var maskHost sql.NullBool
+	if errorsJSON.Valid && errorsJSON.String != "" {
+		var breakdown ErrorBreakdown
+		if err := json.Unmarshal([]byte(errorsJSON.String), &breakdown); err == nil {
+			testRun.ErrorBreakdown = &breakdown
+		}
+	}
 
-	err := db.QueryRow(
-		`SELECT id, uuid, host, mask_host, total_users, ramp_up_sec, duration, status, started_at, completed_at,
-		 total_requests, success_count, error_count, avg_latency, min_latency, max_latency, rps,
-		 method, body, headers
-		 FROM test_runs WHERE uuid = ?`,
-		uuid,
-	).Scan(
-		&testRun.ID, &testRun.UUID, &testRun.Host, &maskHost, &testRun.TotalUsers, &testRun.RampUpSec, &testRun.Duration,
-		&testRun.Status, &testRun.StartedAt, &completedAt,
-		&testRun.TotalRequests, &testRun.SuccessCount, &testRun.ErrorCount,
-		&testRun.AvgLatency, &testRun.MinLatency, &testRun.MaxLatency, &testRun.RPS,
-		&method, &body, &headersJSON,
-	)
-	if err != nil {
-		return nil, err
+	if transportJSON.Valid && transportJSON.String != "" {
+		var transport TransportConfig
+		if err := json.Unmarshal([]byte(transportJSON.String), &transport); err == nil {
+			testRun.Transport = transport
+		}
 	}
 
-	if completedAt.Valid {
-		testRun.CompletedAt = &completedAt.Time
+	if scenarioJSON.Valid && scenarioJSON.String != "" {
+		var scenario []ScenarioStep
+		if err := json.Unmarshal([]byte(scenarioJSON.String), &scenario); err == nil {
+			testRun.Scenario = scenario
+		}
 	}
 
-	if method.Valid {
-		testRun.Method = method.String
+	if mixJSON.Valid && mixJSON.String != "" {
+		var mix []ScenarioStep
+		if err := json.Unmarshal([]byte(mixJSON.String), &mix); err == nil {
+			testRun.RequestMix = mix
+		}
 	}
-	if body.Valid {
-		testRun.Body = body.String
+
+	if assertionsJSON.Valid && assertionsJSON.String != "" {
+		var assertions []Assertion
+		if err := json.Unmarshal([]byte(assertionsJSON.String), &assertions); err == nil {
+			testRun.Assertions = assertions
+		}
 	}
-	if headersJSON.Valid && headersJSON.String != "" {
-		var headers map[string]string
-		if err := json.Unmarshal([]byte(headersJSON.String), &headers); err == nil {
-			testRun.Headers = headers
+
+	if dataJSON.Valid && dataJSON.String != "" {
+		var data DataSummary
+		if err := json.Unmarshal([]byte(dataJSON.String), &data); err == nil {
+			testRun.Data = &data
 		}
 	}
-	if maskHost.Valid {
-		testRun.MaskHost = maskHost.Bool
-	} else {
-		testRun.MaskHost = true
+
+	if thinkTimeJSON.Valid && thinkTimeJSON.String != "" {
+		var thinkTime ThinkTime
+		if err := json.Unmarshal([]byte(thinkTimeJSON.String), &thinkTime); err == nil {
+			testRun.ThinkTime = &thinkTime
+		}
+	}
+	if pacingMs.Valid {
+		testRun.PacingMs = int(pacingMs.Int64)
+	}
+
+	if cookiesJSON.Valid && cookiesJSON.String != "" {
+		var cookies CookieConfig
+		if err := json.Unmarshal([]byte(cookiesJSON.String), &cookies); err == nil {
+			testRun.Cookies = &cookies
+		}
+	}
+	// Tests recorded before redirects were configurable followed up to 10
+	testRun.Redirects = RedirectConfig{Policy: RedirectFollow, MaxHops: DefaultMaxRedirects}
+	if redirectsJSON.Valid && redirectsJSON.String != "" {
+		var redirects RedirectConfig
+		if err := json.Unmarshal([]byte(redirectsJSON.String), &redirects); err == nil {
+			testRun.Redirects = redirects
+		}
+	}
+	if sessions.Valid {
+		testRun.SessionsEstablished = sessions.Int64
+	}
+	if tokenJSON.Valid && tokenJSON.String != "" {
+		var tokens TokenMetrics
+		if err := json.Unmarshal([]byte(tokenJSON.String), &tokens); err == nil {
+			testRun.TokenMetrics = &tokens
+		}
+	}
+	if tlsJSON.Valid && tlsJSON.String != "" {
+		var summary TLSSummary
+		if err := json.Unmarshal([]byte(tlsJSON.String), &summary); err == nil {
+			testRun.TLS = &summary
+		}
+	}
+	if tlsSessionsJSON.Valid && tlsSessionsJSON.String != "" {
+		var sessions []TLSSession
+		if err := json.Unmarshal([]byte(tlsSessionsJSON.String), &sessions); err == nil {
+			testRun.TLSSessions = sessions
+		}
+	}
+	if webSocketJSON.Valid && webSocketJSON.String != "" {
+		var webSocket WebSocketConfig
+		if err := json.Unmarshal([]byte(webSocketJSON.String), &webSocket); err == nil {
+			testRun.WebSocket = &webSocket
+		}
+	}
+	if webSocketMetricsJSON.Valid && webSocketMetricsJSON.String != "" {
+		var webSocketMetrics WebSocketMetrics
+		if err := json.Unmarshal([]byte(webSocketMetricsJSON.String), &webSocketMetrics); err == nil {
+			testRun.WebSocketMetrics = &webSocketMetrics
+		}
+	}
+	if grpcJSON.Valid && grpcJSON.String != "" {
+		var grpcConfig GRPCConfig
+		if err := json.Unmarshal([]byte(grpcJSON.String), &grpcConfig); err == nil {
+			testRun.GRPC = &grpcConfig
+		}
+	}
+	if grpcMetricsJSON.Valid && grpcMetricsJSON.String != "" {
+		var grpcMetrics GRPCMetrics
+		if err := json.Unmarshal([]byte(grpcMetricsJSON.String), &grpcMetrics); err == nil {
+			testRun.GRPCMetrics = &grpcMetrics
+		}
+	}
+	if graphQLJSON.Valid && graphQLJSON.String != "" {
+		var graphQL GraphQLRequest
+		if err := json.Unmarshal([]byte(graphQLJSON.String), &graphQL); err == nil {
+			testRun.GraphQL = &graphQL
+		}
+	}
+	if graphQLOperationsJSON.Valid && graphQLOperationsJSON.String != "" {
+		var operations []GraphQLMetrics
+		if err := json.Unmarshal([]byte(graphQLOperationsJSON.String), &operations); err == nil {
+			testRun.GraphQLOperations = operations
+		}
+	}
+	if streamJSON.Valid && streamJSON.String != "" {
+		var stream StreamConfig
+		if err := json.Unmarshal([]byte(streamJSON.String), &stream); err == nil {
+			testRun.Stream = &stream
+		}
+	}
+	if streamMetricsJSON.Valid && streamMetricsJSON.String != "" {
+		var streamMetrics StreamMetrics
+		if err := json.Unmarshal([]byte(streamMetricsJSON.String), &streamMetrics); err == nil {
+			testRun.StreamMetrics = &streamMetrics
+		}
+	}
+	if socketJSON.Valid && socketJSON.String != "" {
+		var socket SocketConfig
+		if err := json.Unmarshal([]byte(socketJSON.String), &socket); err == nil {
+			testRun.Socket = &socket
+		}
+	}
+	if socketMetricsJSON.Valid && socketMetricsJSON.String != "" {
+		var socketMetrics SocketMetrics
+		if err := json.Unmarshal([]byte(socketMetricsJSON.String), &socketMetrics); err == nil {
+			testRun.SocketMetrics = &socketMetrics
+		}
+	}
+
+	if stepsJSON.Valid && stepsJSON.String != "" {
+		var steps []StepMetrics
+		if err := json.Unmarshal([]byte(stepsJSON.String), &steps); err == nil {
+			testRun.StepMetrics = steps
+		}
+	}
+
+	if statusCodesJSON.Valid && statusCodesJSON.String != "" {
+		var codes map[int]int64
+		if err := json.Unmarshal([]byte(statusCodesJSON.String), &codes); err == nil {
+			testRun.StatusCodes = codes
+		}
 	}
 
 	return &testRun, nil
 }
 
+func GetTestRun(db *sql.DB, id int64) (*TestRun, error) {
+	return scanTestRun(db.QueryRow(`SELECT `+testRunColumns+` FROM test_runs WHERE id = ?`, id))
+}
+
+func GetTestRunByUUID(db *sql.DB, uuid string) (*TestRun, error) {
+	return scanTestRun(db.QueryRow(`SELECT `+testRunColumns+` FROM test_runs WHERE uuid = ?`, uuid))
+}
+
 func GetTopTestRuns(db *sql.DB, limit int) ([]TestRun, error) {
 	rows, err := db.Query(
-		`SELECT id, uuid, host, mask_host, total_users, ramp_up_sec, duration, status, started_at, completed_at,
-		 total_requests, success_count, error_count, avg_latency, min_latency, max_latency, rps,
-		 method, body, headers
+		`SELECT `+testRunColumns+`
 		 FROM test_runs
 		 ORDER BY started_at DESC
 		 LIMIT ?`,
@@ -389,66 +908,58 @@ func GetTopTestRuns(db *sql.DB, limit int) ([]TestRun, error) {
 
 	var testRuns []TestRun
 	for rows.Next() {
-		var testRun TestRun
-		var completedAt sql.NullTime
-		var method, body, headersJSON sql.NullString
-		var maskHost sql.NullBool
-
-		err := rows.Scan(
-			&testRun.ID, &testRun.UUID, &testRun.Host, &maskHost, &testRun.TotalUsers, &testRun.RampUpSec, &testRun.Duration,
-			&testRun.Status, &testRun.StartedAt, &completedAt,
-			&testRun.TotalRequests, &testRun.SuccessCount, &testRun.ErrorCount,
-			&testRun.AvgLatency, &testRun.MinLatency, &testRun.MaxLatency, &testRun.RPS,
-			&method, &body, &headersJSON,
-		)
+		testRun, err := scanTestRun(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if completedAt.Valid {
-			testRun.CompletedAt = &completedAt.Time
-		}
-
-		if method.Valid {
-			testRun.Method = method.String
-		}
-		if body.Valid {
-			testRun.Body = body.String
-		}
-		if headersJSON.Valid && headersJSON.String != "" {
-			var headers map[string]string
-			if err := json.Unmarshal([]byte(headersJSON.String), &headers); err == nil {
-				testRun.Headers = headers
-			}
-		}
-		if maskHost.Valid {
-			testRun.MaskHost = maskHost.Bool
-		} else {
-			testRun.MaskHost = true
-		}
-
-		testRuns = append(testRuns, testRun)
+		testRuns = append(testRuns, *testRun)
 	}
 
 	return testRuns, rows.Err()
 }
 
-func SaveRequestMetric(db *sql.DB, metric *RequestMetric) error {
-	success := 0
-	if metric.Success {
-		success = 1
+// SaveRequestMetrics stores a batch of request metrics in one transaction.
+func SaveRequestMetrics(db *sql.DB, metrics []*RequestMetric) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
 	}
-	_, err := db.Exec(
-		`INSERT INTO request_metrics (test_run_id, timestamp, latency, success, status_code)
-		 VALUES (?, ?, ?, ?, ?)`,
-		metric.TestRunID, metric.Timestamp, metric.Latency, success, metric.StatusCode,
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO request_metrics (test_run_id, timestamp, latency, corrected_latency, success, status_code,
+		 dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms, error_category, grpc_status)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, metric := range metrics {
+		success := 0
+		if metric.Success {
+			success = 1
+		}
+		_, err := stmt.Exec(
+			metric.TestRunID, metric.Timestamp, metric.Latency, metric.CorrectedLatency, success, metric.StatusCode,
+			metric.Phases.DNS, metric.Phases.Connect, metric.Phases.TLS, metric.Phases.TTFB, metric.Phases.Download,
+			sql.NullString{String: metric.ErrorCategory, Valid: metric.ErrorCategory != ""},
+			sql.NullString{String: metric.GRPCStatus, Valid: metric.GRPCStatus != ""},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func GetRequestMetrics(db *sql.DB, testRunID int64) ([]*RequestMetric, error) {
 	rows, err := db.Query(
-		`SELECT test_run_id, timestamp, latency, success, status_code
+		`SELECT test_run_id, timestamp, latency, COALESCE(corrected_latency, latency), success, status_code,
+		 COALESCE(dns_ms, 0), COALESCE(connect_ms, 0), COALESCE(tls_ms, 0), COALESCE(ttfb_ms, 0), COALESCE(download_ms, 0),
+		 COALESCE(error_category, ''), COALESCE(grpc_status, '')
 		 FROM request_metrics
 		 WHERE test_run_id = ?
 		 ORDER BY timestamp ASC`,
@@ -468,8 +979,16 @@ func GetRequestMetrics(db *sql.DB, testRunID int64) ([]*RequestMetric, error) {
 			&metric.TestRunID,
 			&metric.Timestamp,
 			&metric.Latency,
+			&metric.CorrectedLatency,
 			&success,
 			&metric.StatusCode,
+			&metric.Phases.DNS,
+			&metric.Phases.Connect,
+			&metric.Phases.TLS,
+			&metric.Phases.TTFB,
+			&metric.Phases.Download,
+			&metric.ErrorCategory,
+			&metric.GRPCStatus,
 		)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// Error categories recorded for failed requests.
+const (
+	ErrorCategoryTimeout           = "timeout"
+	ErrorCategoryConnectionRefused = "connection_refused"
+	ErrorCategoryConnectionReset   = "connection_reset"
+	ErrorCategoryDNSFailure        = "dns_failure"
+	ErrorCategoryTLSError          = "tls_error"           // The target's certificate could not be verified
+	ErrorCategoryTLSHandshake      = "tls_handshake_error" // The TLS handshake failed, e.g. a rejected client certificate
+	ErrorCategoryContextCancelled  = "context_cancelled"
+	ErrorCategoryBodyReadError     = "body_read_error"
+	ErrorCategoryRequestError      = "request_error"     // The request could not be built
+	ErrorCategoryExtractionFailed  = "extraction_failed" // An extractor without a default matched nothing
+	ErrorCategoryRedirect          = "redirect_error"    // Too many redirects, or a redirect to a blocked host
+	ErrorCategoryTokenError        = "token_error"       // No OAuth2 token could be fetched, so the request was not sent
+	ErrorCategoryWebSocket         = "websocket_error"   // The target closed a WebSocket connection or broke the protocol
+	ErrorCategoryHTTP4xx           = "http_4xx"
+	ErrorCategoryHTTP5xx           = "http_5xx"
+	ErrorCategoryUnknown           = "unknown"
+)
+
+// maxErrorSampleLength caps the sample message kept for each category.
+const maxErrorSampleLength = 300
+
+// ErrorBreakdown counts a test's failed requests by category and by status
+// code (0 when no response was received), keeping one sample message per
+// category.
+type ErrorBreakdown struct {
+	Categories  map[string]*ErrorCategoryStats `json:"categories"`
+	StatusCodes map[int]int64                  `json:"status_codes"`
+}
+
+type ErrorCategoryStats struct {
+	Count  int64  `json:"count"`
+	Sample string `json:"sample,omitempty"`
+}
+
+func newErrorBreakdown() ErrorBreakdown {
+	return ErrorBreakdown{
+		Categories:  make(map[string]*ErrorCategoryStats),
+		StatusCodes: make(map[int]int64),
+	}
+}
+
+func (eb ErrorBreakdown) add(category string, statusCode int, sample string) {
+	stats, ok := eb.Categories[category]
+	if !ok {
+		stats = &ErrorCategoryStats{Sample: truncateSample(sample)}
+		eb.Categories[category] = stats
+	}
+	stats.Count++
+	eb.StatusCodes[statusCode]++
+}
+
+// clone returns a deep copy that is safe to use outside the collector lock.
+func (eb ErrorBreakdown) clone() ErrorBreakdown {
+	out := newErrorBreakdown()
+	for category, stats := range eb.Categories {
+		copied := *stats
+		out.Categories[category] = &copied
+	}
+	for code, count := range eb.StatusCodes {
+		out.StatusCodes[code] = count
+	}
+	return out
+}
+
+// classifyError maps a transport error to an error category.
+func classifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorCategoryContextCancelled
+	}
+
+	if errors.Is(err, errRedirectLimit) || errors.Is(err, errRedirectBlocked) {
+		return ErrorCategoryRedirect
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorCategoryDNSFailure
+	}
+
+	if isCertificateError(err) {
+		return ErrorCategoryTLSError
+	}
+	if isTLSHandshakeError(err) {
+		return ErrorCategoryTLSHandshake
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorCategoryTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorCategoryTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorCategoryConnectionRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorCategoryConnectionReset
+	}
+
+	return ErrorCategoryUnknown
+}
+
+// isCertificateError reports whether the target's certificate failed
+// verification.
+func isCertificateError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
+
+// isTLSHandshakeError reports whether a TLS handshake failed for another
+// reason, such as an alert from the target rejecting the client certificate
+// or no shared protocol version or cipher suite.
+func isTLSHandshakeError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+
+	return errors.As(err, &recordErr) ||
+		errors.As(err, &alertErr) ||
+		strings.Contains(err.Error(), "tls: ")
+}
+
+// statusErrorCategory returns the category of a response that failed only
+// because of its status code.
+func statusErrorCategory(statusCode int) string {
+	if statusCode >= 500 {
+		return ErrorCategoryHTTP5xx
+	}
+	return ErrorCategoryHTTP4xx
+}
+
+// errorSample returns an error message suitable for reports. The URL that
+// net/http prefixes to transport errors is dropped so masked hosts stay
+// masked.
+func errorSample(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+func truncateSample(sample string) string {
+	if len(sample) > maxErrorSampleLength {
+		return sample[:maxErrorSampleLength] + "..."
+	}
+	return sample
+}
+
+// errorBreakdownFromMetrics rebuilds a breakdown from stored request metrics,
+// for tests recorded before breakdowns were persisted. Samples are not
+// available for those tests.
+func errorBreakdownFromMetrics(metrics []*RequestMetric) ErrorBreakdown {
+	breakdown := newErrorBreakdown()
+	for _, m := range metrics {
+		if m.Success {
+			continue
+		}
+		category := m.ErrorCategory
+		if category == "" && m.StatusCode >= 400 {
+			category = statusErrorCategory(m.StatusCode)
+		} else if category == "" {
+			category = ErrorCategoryUnknown
+		}
+		breakdown.add(category, m.StatusCode, "")
+	}
+	return breakdown
+}
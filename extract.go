@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Extractor types.
+const (
+	ExtractorJSON   = "json"   // JSONPath into the response body, e.g. $.data.items[0].id
+	ExtractorRegex  = "regex"  // First match in the response body; the first group if there is one
+	ExtractorHeader = "header" // Response header value
+)
+
+const (
+	MaxExtractorsPerStep = 10
+	MaxCaptureBytes      = 1 << 20 // Response body kept for extractors and assertions; the rest is discarded
+	maxVariableNameLen   = 64
+)
+
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Extractor saves a value from a step's response into a variable. Later steps
+// of the same user reference it as {{name}} in their URL, headers and body.
+type Extractor struct {
+	Name       string `json:"name"`              // Variable name
+	Type       string `json:"type"`              // "json", "regex" or "header"
+	Expression string `json:"expression"`        // JSONPath, regular expression or header name
+	Default    string `json:"default,omitempty"` // Used when nothing matches; without it the request fails
+
+	regex    *regexp.Regexp
+	jsonPath []jsonPathSegment
+}
+
+// jsonPathSegment is an object key or, when isIndex is set, an array index.
+type jsonPathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// validateExtractors checks a step's extractors and compiles their
+// expressions.
+func validateExtractors(extractors []Extractor) error {
+	if len(extractors) > MaxExtractorsPerStep {
+		return fmt.Errorf("at most %d extractors are allowed", MaxExtractorsPerStep)
+	}
+	for i := range extractors {
+		extractor := &extractors[i]
+		if len(extractor.Name) > maxVariableNameLen || !variableNamePattern.MatchString(extractor.Name) {
+			return fmt.Errorf("extractor %d: name must be a letter or underscore followed by letters, digits or underscores", i+1)
+		}
+		if _, reserved := templateFuncs[extractor.Name]; reserved {
+			return fmt.Errorf("extractor %q: name is reserved for a template function", extractor.Name)
+		}
+		if extractor.Expression == "" {
+			return fmt.Errorf("extractor %q: expression is required", extractor.Name)
+		}
+		switch extractor.Type {
+		case ExtractorJSON:
+			path, err := parseJSONPath(extractor.Expression)
+			if err != nil {
+				return fmt.Errorf("extractor %q: %v", extractor.Name, err)
+			}
+			extractor.jsonPath = path
+		case ExtractorRegex:
+			re, err := regexp.Compile(extractor.Expression)
+			if err != nil {
+				return fmt.Errorf("extractor %q: invalid regex: %v", extractor.Name, err)
+			}
+			extractor.regex = re
+		case ExtractorHeader:
+			extractor.Expression = http.CanonicalHeaderKey(extractor.Expression)
+		default:
+			return fmt.Errorf("extractor %q: type must be one of %v", extractor.Name, []string{ExtractorJSON, ExtractorRegex, ExtractorHeader})
+		}
+	}
+	return nil
+}
+
+// parseJSONPath parses the supported JSONPath subset: a leading $ followed by
+// .key, ['key'] and [index] selectors.
+func parseJSONPath(expr string) ([]jsonPathSegment, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("JSONPath must start with $")
+	}
+	rest := expr[1:]
+	var path []jsonPathSegment
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("empty key in JSONPath %q", expr)
+			}
+			path = append(path, jsonPathSegment{key: key})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in JSONPath %q", expr)
+			}
+			selector := rest[1:end]
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				path = append(path, jsonPathSegment{key: selector[1 : len(selector)-1]})
+			} else {
+				index, err := strconv.Atoi(selector)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid selector [%s] in JSONPath %q", selector, expr)
+				}
+				path = append(path, jsonPathSegment{index: index, isIndex: true})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in JSONPath %q", rest[0], expr)
+		}
+	}
+	return path, nil
+}
+
+// lookupJSONPath returns the value at path in a decoded JSON document.
+func lookupJSONPath(doc interface{}, path []jsonPathSegment) (interface{}, bool) {
+	value := doc
+	for _, segment := range path {
+		if segment.isIndex {
+			items, ok := value.([]interface{})
+			if !ok || segment.index >= len(items) {
+				return nil, false
+			}
+			value = items[segment.index]
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[segment.key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// jsonValueString formats an extracted JSON value for use in a request.
+// Objects and arrays are re-encoded as JSON.
+func jsonValueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	}
+}
+
+// stepCapturesBody reports whether a step's extractors or assertions read the
+// response body.
+func stepCapturesBody(step *ScenarioStep) bool {
+	if step.GraphQL != nil {
+		return true
+	}
+	for _, extractor := range step.Extract {
+		if extractor.Type != ExtractorHeader {
+			return true
+		}
+	}
+	for _, assertion := range step.Assertions {
+		switch assertion.Type {
+		case AssertionBodyContains, AssertionBodyRegex, AssertionJSON:
+			return true
+		}
+	}
+	return false
+}
+
+// runExtractors stores the step's extracted values in the user's variables.
+// It returns an error for the first extractor that matched nothing and has no
+// default.
+func runExtractors(step *ScenarioStep, resp *http.Response, body []byte, user *virtualUser) error {
+	if user.vars == nil {
+		user.vars = make(map[string]string, len(step.Extract))
+	}
+	var doc interface{}
+	decoded := false
+	for i := range step.Extract {
+		extractor := &step.Extract[i]
+		value, found := "", false
+		switch extractor.Type {
+		case ExtractorJSON:
+			if !decoded {
+				decoded = true
+				if err := json.Unmarshal(body, &doc); err != nil {
+					doc = nil
+				}
+			}
+			if doc != nil {
+				var raw interface{}
+				if raw, found = lookupJSONPath(doc, extractor.jsonPath); found {
+					value = jsonValueString(raw)
+				}
+			}
+		case ExtractorRegex:
+			if match := extractor.regex.FindSubmatch(body); match != nil {
+				found = true
+				value = string(match[0])
+				if len(match) > 1 {
+					value = string(match[1])
+				}
+			}
+		case ExtractorHeader:
+			if values := resp.Header.Values(extractor.Expression); len(values) > 0 {
+				found = true
+				value = values[0]
+			}
+		}
+
+		if !found {
+			if extractor.Default == "" {
+				return fmt.Errorf("extractor %q: %s %q matched nothing", extractor.Name, extractor.Type, extractor.Expression)
+			}
+			value = extractor.Default
+		}
+		user.vars[extractor.Name] = value
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Data file formats.
+const (
+	DataFormatCSV   = "csv"   // Header row followed by one row per record
+	DataFormatJSONL = "jsonl" // One JSON object per line
+)
+
+// Data feeder modes.
+const (
+	FeederSequential = "sequential" // Rows in order, shared by all users; the test ends when they run out
+	FeederCircular   = "circular"   // Rows in order, starting over after the last (default)
+	FeederRandom     = "random"     // A random row for every iteration
+	FeederUnique     = "unique"     // One row per virtual user for all its iterations, never shared
+)
+
+const (
+	MaxDataBytes   = 5 << 20 // Maximum size of a test's data file
+	MaxDataRows    = 100000
+	MaxDataColumns = 50
+)
+
+// DataConfig is the data file sent to /api/start. Content is the file itself.
+type DataConfig struct {
+	Format  string `json:"format,omitempty"`
+	Mode    string `json:"mode,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// DataSummary describes a test's data file. Only the summary is stored with
+// the test; the content is discarded once the test ends.
+type DataSummary struct {
+	Format   string   `json:"format"`
+	Mode     string   `json:"mode"`
+	Rows     int      `json:"rows"`
+	Columns  []string `json:"columns"`
+	Bytes    int      `json:"bytes"`
+	Checksum string   `json:"sha256"`
+}
+
+// dataFeeder hands rows of a test's data to its virtual users.
+type dataFeeder struct {
+	mode      string
+	rows      []map[string]string
+	next      atomic.Int64 // Index of the next row for sequential, circular and unique modes
+	exhausted sync.Once
+	onEmpty   func() // Called once when a sequential or unique feeder runs out
+}
+
+// validateDataMode checks a feeder mode, defaulting to circular.
+func validateDataMode(mode string) (string, error) {
+	if mode == "" {
+		return FeederCircular, nil
+	}
+	switch mode {
+	case FeederSequential, FeederCircular, FeederRandom, FeederUnique:
+		return mode, nil
+	}
+	return "", fmt.Errorf("mode must be one of %v", []string{FeederSequential, FeederCircular, FeederRandom, FeederUnique})
+}
+
+// parseData parses a data file into rows keyed by column name. Column names
+// must be usable as template variables.
+func parseData(format, content string) ([]string, []map[string]string, error) {
+	if len(content) > MaxDataBytes {
+		return nil, nil, fmt.Errorf("data must be at most %d bytes", MaxDataBytes)
+	}
+
+	var columns []string
+	var rows []map[string]string
+	var err error
+	switch format {
+	case DataFormatCSV:
+		columns, rows, err = parseCSVData(content)
+	case DataFormatJSONL:
+		columns, rows, err = parseJSONLData(content)
+	default:
+		return nil, nil, fmt.Errorf("format must be one of %v", []string{DataFormatCSV, DataFormatJSONL})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("data has no rows")
+	}
+	if len(rows) > MaxDataRows {
+		return nil, nil, fmt.Errorf("data must have at most %d rows", MaxDataRows)
+	}
+	if len(columns) > MaxDataColumns {
+		return nil, nil, fmt.Errorf("data must have at most %d columns", MaxDataColumns)
+	}
+	for _, column := range columns {
+		if len(column) > maxVariableNameLen || !variableNamePattern.MatchString(column) {
+			return nil, nil, fmt.Errorf("column %q: names must be a letter or underscore followed by letters, digits or underscores", column)
+		}
+		if _, reserved := templateFuncs[column]; reserved {
+			return nil, nil, fmt.Errorf("column %q: name is reserved for a template function", column)
+		}
+	}
+	return columns, rows, nil
+}
+
+func parseCSVData(content string) ([]string, []map[string]string, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV header: %v", err)
+	}
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if seen[name] {
+			return nil, nil, fmt.Errorf("duplicate column %q", name)
+		}
+		seen[name] = true
+		columns[i] = name
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+		if len(rows) > MaxDataRows {
+			break
+		}
+	}
+	return columns, rows, nil
+}
+
+func parseJSONLData(content string) ([]string, []map[string]string, error) {
+	var columns []string
+	seen := make(map[string]bool)
+	var rows []map[string]string
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), MaxDataBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, nil, fmt.Errorf("line %d: expected a JSON object: %v", line, err)
+		}
+		row := make(map[string]string, len(record))
+		for key, value := range record {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+			row[key] = jsonValueString(value)
+		}
+		rows = append(rows, row)
+		if len(rows) > MaxDataRows {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSONL: %v", err)
+	}
+	return columns, rows, nil
+}
+
+// dataChecksum identifies a data file, so reports of tests that used the same
+// data can be matched.
+func dataChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadDataConfig parses the data of a /api/start request. It returns the
+// summary stored with the new test and the parsed rows.
+func loadDataConfig(cfg *DataConfig) (*DataSummary, []map[string]string, error) {
+	mode, err := validateDataMode(cfg.Mode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns, rows, err := parseData(cfg.Format, cfg.Content)
+	if err != nil {
+		return nil, nil, err
+	}
+	summary := &DataSummary{
+		Format:   cfg.Format,
+		Mode:     mode,
+		Rows:     len(rows),
+		Columns:  columns,
+		Bytes:    len(cfg.Content),
+		Checksum: dataChecksum(cfg.Content),
+	}
+	return summary, rows, nil
+}
+
+func newDataFeeder(mode string, rows []map[string]string, onEmpty func()) *dataFeeder {
+	return &dataFeeder{mode: mode, rows: rows, onEmpty: onEmpty}
+}
+
+// draw sets the row a user's next iteration runs with. It returns false when
+// a sequential or unique feeder has no rows left.
+func (f *dataFeeder) draw(user *virtualUser) bool {
+	switch f.mode {
+	case FeederRandom:
+		user.row = f.rows[user.intn(len(f.rows))]
+		return true
+	case FeederCircular:
+		user.row = f.rows[(f.next.Add(1)-1)%int64(len(f.rows))]
+		return true
+	case FeederUnique:
+		if user.row != nil {
+			return true
+		}
+	}
+
+	index := f.next.Add(1) - 1
+	if index >= int64(len(f.rows)) {
+		f.exhausted.Do(func() {
+			slog.Info("Test data exhausted, ending test", "mode", f.mode, "rows", len(f.rows))
+			if f.onEmpty != nil {
+				f.onEmpty()
+			}
+		})
+		return false
+	}
+	user.row = f.rows[index]
+	return true
+}
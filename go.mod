@@ -3,8 +3,18 @@ module load-tester
 go 1.21
 
 require (
+	github.com/HdrHistogram/hdrhistogram-go v1.1.2
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/mattn/go-sqlite3 v1.14.18
+	golang.org/x/net v0.35.0
+	google.golang.org/grpc v1.67.1
+	google.golang.org/protobuf v1.36.5
 )
 
-require github.com/google/uuid v1.6.0 // indirect
+require (
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
+)
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	MaxGraphQLQueryBytes     = 64 << 10
+	MaxGraphQLVariablesBytes = 64 << 10
+	MaxGraphQLErrorKinds     = 50 // Distinct GraphQL error categories per test; later messages share one
+	maxGraphQLCategoryLength = 100
+
+	graphQLAnonymous = "anonymous" // Operation name reported for unnamed operations
+)
+
+// ErrorCategoryGraphQLResponse is recorded when a GraphQL response is not a
+// JSON object. Responses with errors are recorded under their first error's
+// message instead.
+const ErrorCategoryGraphQLResponse = "graphql_invalid_response"
+
+// graphQLOtherErrors is the category of GraphQL errors once a test has
+// recorded MaxGraphQLErrorKinds distinct messages.
+const graphQLOtherErrors = "graphql: other errors"
+
+// graphQLOperationPattern finds the first named operation of a document.
+var graphQLOperationPattern = regexp.MustCompile(`(?:^|[\s}])(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+// GraphQLRequest makes a request a GraphQL operation: it is POSTed as a JSON
+// body of the query, operation name and variables.
+type GraphQLRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operation_name,omitempty"`
+	Variables     json.RawMessage `json:"variables,omitempty"` // JSON object; its string values are templates
+}
+
+// GraphQLMetrics summarises the requests of one GraphQL operation.
+type GraphQLMetrics struct {
+	Operation     string       `json:"operation"`
+	TotalRequests int64        `json:"total_requests"`
+	SuccessCount  int64        `json:"success_count"`
+	ErrorCount    int64        `json:"error_count"`
+	GraphQLErrors int64        `json:"graphql_errors"` // Responses with a non-empty errors array
+	ErrorRate     float64      `json:"error_rate"`
+	Latency       LatencyStats `json:"latency"`
+}
+
+// graphQLTemplate is a compiled GraphQLRequest.
+type graphQLTemplate struct {
+	query, operationName string
+	operation            string      // Name the operation is reported under
+	variables            interface{} // Decoded variables with string values replaced by templates
+}
+
+// graphQLCollector accumulates the requests of a test's GraphQL operations.
+type graphQLCollector struct {
+	operations map[string]*graphQLOperation
+	categories map[string]bool // GraphQL error categories recorded so far
+}
+
+type graphQLOperation struct {
+	order         int
+	latencies     *hdrhistogram.Histogram
+	successCount  int64
+	errorCount    int64
+	graphQLErrors int64
+}
+
+// validateGraphQLRequest checks a GraphQL request and compiles it.
+func validateGraphQLRequest(cfg *GraphQLRequest) (*graphQLTemplate, error) {
+	if strings.TrimSpace(cfg.Query) == "" {
+		return nil, fmt.Errorf("graphql query is required")
+	}
+	if len(cfg.Query) > MaxGraphQLQueryBytes {
+		return nil, fmt.Errorf("graphql query must be at most %d bytes", MaxGraphQLQueryBytes)
+	}
+	if cfg.OperationName != "" && (len(cfg.OperationName) > MaxStepNameLength || !variableNamePattern.MatchString(cfg.OperationName)) {
+		return nil, fmt.Errorf("graphql operation_name %q is not a valid name", cfg.OperationName)
+	}
+	if len(cfg.Variables) > MaxGraphQLVariablesBytes {
+		return nil, fmt.Errorf("graphql variables must be at most %d bytes", MaxGraphQLVariablesBytes)
+	}
+
+	t := &graphQLTemplate{query: cfg.Query, operationName: cfg.OperationName, operation: graphQLOperationName(cfg)}
+	if len(cfg.Variables) > 0 && string(cfg.Variables) != "null" {
+		decoder := json.NewDecoder(bytes.NewReader(cfg.Variables))
+		decoder.UseNumber()
+		var variables interface{}
+		if err := decoder.Decode(&variables); err != nil {
+			return nil, fmt.Errorf("graphql variables: %v", err)
+		}
+		if _, ok := variables.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("graphql variables must be a JSON object")
+		}
+		var err error
+		if t.variables, err = compileGraphQLValue(variables); err != nil {
+			return nil, fmt.Errorf("graphql variables: %v", err)
+		}
+	}
+	return t, nil
+}
+
+// graphQLOperationName is the name a request's metrics are reported under:
+// its operation name, or else the name of the query's first operation.
+func graphQLOperationName(cfg *GraphQLRequest) string {
+	if cfg.OperationName != "" {
+		return cfg.OperationName
+	}
+	if match := graphQLOperationPattern.FindStringSubmatch(cfg.Query); match != nil {
+		return match[1]
+	}
+	return graphQLAnonymous
+}
+
+// compileGraphQLValue replaces the strings of a decoded JSON value with their
+// templates.
+func compileGraphQLValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return parseTemplate(v)
+	case map[string]interface{}:
+		for key, element := range v {
+			compiled, err := compileGraphQLValue(element)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = compiled
+		}
+	case []interface{}:
+		for i, element := range v {
+			compiled, err := compileGraphQLValue(element)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = compiled
+		}
+	}
+	return value, nil
+}
+
+// renderGraphQLValue renders the templates of a compiled value into a new
+// value, leaving the compiled one untouched for other requests.
+func renderGraphQLValue(value interface{}, user *virtualUser) interface{} {
+	switch v := value.(type) {
+	case *requestTemplate:
+		return v.render(user)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			rendered[key] = renderGraphQLValue(element, user)
+		}
+		return rendered
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, element := range v {
+			rendered[i] = renderGraphQLValue(element, user)
+		}
+		return rendered
+	}
+	return value
+}
+
+// render returns the JSON body of one request of the operation.
+func (t *graphQLTemplate) render(user *virtualUser) string {
+	body := struct {
+		Query         string      `json:"query"`
+		OperationName string      `json:"operationName,omitempty"`
+		Variables     interface{} `json:"variables,omitempty"`
+	}{t.query, t.operationName, renderGraphQLValue(t.variables, user)}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		// Rendered variables are strings, numbers, booleans and nulls
+		return ""
+	}
+	return string(encoded)
+}
+
+// graphQLErrors returns the messages of a response's errors array, which is
+// empty for a successful operation. Only the captured start of the body is
+// read: when it was cut short, errors listed after a larger data object are
+// not seen, and the response is taken as successful.
+func graphQLErrors(body []byte, truncated bool) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("response is not JSON: %v", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("response is not a JSON object")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, truncatedGraphQL(err, truncated)
+		}
+		if key, _ := token.(string); key != "errors" {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return nil, truncatedGraphQL(err, truncated)
+			}
+			continue
+		}
+		var entries []struct {
+			Message string `json:"message"`
+		}
+		if err := decoder.Decode(&entries); err != nil {
+			return nil, truncatedGraphQL(err, truncated)
+		}
+		messages := make([]string, len(entries))
+		for i, entry := range entries {
+			messages[i] = entry.Message
+		}
+		return messages, nil
+	}
+	return nil, nil
+}
+
+// truncatedGraphQL ignores a decoding error caused by the end of a captured
+// body that was cut short.
+func truncatedGraphQL(err error, truncated bool) error {
+	if truncated {
+		return nil
+	}
+	return fmt.Errorf("response is not valid JSON: %v", err)
+}
+
+// RecordGraphQL adds a request to its operation's metrics. graphQLError is
+// set when the response carried GraphQL errors.
+func (mc *MetricsCollector) RecordGraphQL(operation string, latency float64, success, graphQLError bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	collector := mc.graphQLLocked()
+	op, ok := collector.operations[operation]
+	if !ok {
+		op = &graphQLOperation{order: len(collector.operations), latencies: newLatencyHistogram(mc.precision)}
+		collector.operations[operation] = op
+	}
+	recordLatency(op.latencies, latency)
+	if success {
+		op.successCount++
+	} else {
+		op.errorCount++
+	}
+	if graphQLError {
+		op.graphQLErrors++
+	}
+}
+
+// GraphQLErrorCategory returns the error category of a GraphQL error message:
+// the message, shortened, prefixed with "graphql: ". Once a test has
+// MaxGraphQLErrorKinds categories, new messages share graphQLOtherErrors.
+func (mc *MetricsCollector) GraphQLErrorCategory(message string) string {
+	message = strings.Join(strings.Fields(message), " ")
+	if message == "" {
+		message = "error without message"
+	}
+	if runes := []rune(message); len(runes) > maxGraphQLCategoryLength {
+		message = string(runes[:maxGraphQLCategoryLength]) + "..."
+	}
+	category := "graphql: " + message
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	collector := mc.graphQLLocked()
+	if !collector.categories[category] {
+		if len(collector.categories) >= MaxGraphQLErrorKinds {
+			return graphQLOtherErrors
+		}
+		collector.categories[category] = true
+	}
+	return category
+}
+
+// graphQLLocked returns the test's GraphQL collector, creating it if needed.
+// mc.mu must be held.
+func (mc *MetricsCollector) graphQLLocked() *graphQLCollector {
+	if mc.graphql == nil {
+		mc.graphql = &graphQLCollector{operations: make(map[string]*graphQLOperation), categories: make(map[string]bool)}
+	}
+	return mc.graphql
+}
+
+// GraphQLOperations summarises every operation recorded so far, in the order
+// they were first recorded, or returns nil for tests without GraphQL requests.
+func (mc *MetricsCollector) GraphQLOperations() []GraphQLMetrics {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	if mc.graphql == nil || len(mc.graphql.operations) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(mc.graphql.operations))
+	for name := range mc.graphql.operations {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return mc.graphql.operations[names[i]].order < mc.graphql.operations[names[j]].order
+	})
+
+	summaries := make([]GraphQLMetrics, 0, len(names))
+	for _, name := range names {
+		op := mc.graphql.operations[name]
+		total := op.successCount + op.errorCount
+		summary := GraphQLMetrics{
+			Operation:     name,
+			TotalRequests: total,
+			SuccessCount:  op.successCount,
+			ErrorCount:    op.errorCount,
+			GraphQLErrors: op.graphQLErrors,
+			Latency:       histogramStats(op.latencies),
+		}
+		if total > 0 {
+			summary.ErrorRate = float64(op.errorCount) / float64(total) * 100
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
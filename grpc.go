@@ -0,0 +1,644 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Where a gRPC test's message types come from.
+const (
+	GRPCSchemaReflection    = "reflection"     // Server reflection, grpc.reflection.v1 or v1alpha
+	GRPCSchemaDescriptorSet = "descriptor_set" // Uploaded FileDescriptorSet
+)
+
+const (
+	MaxGRPCPayloadBytes       = 64 << 10
+	MaxGRPCDescriptorSetBytes = 4 << 20 // Decoded
+	MaxGRPCConnections        = 100
+
+	grpcReflectionTimeout = 10 * time.Second
+)
+
+// grpcCodeNames are the canonical names of the gRPC status codes, as used in
+// status_codes and error categories.
+var grpcCodeNames = map[codes.Code]string{
+	codes.OK:                 "OK",
+	codes.Canceled:           "CANCELLED",
+	codes.Unknown:            "UNKNOWN",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.NotFound:           "NOT_FOUND",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.PermissionDenied:   "PERMISSION_DENIED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Aborted:            "ABORTED",
+	codes.OutOfRange:         "OUT_OF_RANGE",
+	codes.Unimplemented:      "UNIMPLEMENTED",
+	codes.Internal:           "INTERNAL",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.DataLoss:           "DATA_LOSS",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+}
+
+// GRPCConfig turns a test into a gRPC test: every request is a call of Method
+// on the test's host, an http:// (plaintext) or https:// URL without a path.
+type GRPCConfig struct {
+	Method        string `json:"method"`                   // Fully-qualified, package.Service/Method
+	Payload       string `json:"payload,omitempty"`        // Request message in protobuf JSON; templated
+	DescriptorSet string `json:"descriptor_set,omitempty"` // Base64 FileDescriptorSet including imports; server reflection when empty
+	Connections   int    `json:"connections,omitempty"`    // HTTP/2 connections the users are spread over (default: 1)
+
+	Schema          string `json:"schema,omitempty"`           // Set when the test starts
+	ServerStreaming bool   `json:"server_streaming,omitempty"` // Set when the test starts
+}
+
+// GRPCMetrics summarises the calls of a gRPC test by status code.
+type GRPCMetrics struct {
+	Calls          int64            `json:"calls"`
+	StatusCodes    map[string]int64 `json:"status_codes"`              // Calls per gRPC status code name
+	StreamMessages int64            `json:"stream_messages,omitempty"` // Responses received by server-streaming calls
+}
+
+// grpcCall is the method a gRPC test calls and the connections shared by its
+// users.
+type grpcCall struct {
+	conns      []*grpc.ClientConn // Users are spread over them by id
+	fullMethod string             // /package.Service/Method
+	method     protoreflect.MethodDescriptor
+	payload    *requestTemplate
+}
+
+// grpcCollector accumulates gRPC status codes while a test runs.
+type grpcCollector struct {
+	codes          map[codes.Code]int64
+	streamMessages int64
+}
+
+// grpcTarget returns the address of a gRPC host and whether it uses TLS.
+func grpcTarget(host string) (string, bool, error) {
+	parsed, err := url.Parse(host)
+	if err != nil {
+		return "", false, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", false, fmt.Errorf("host must be an http:// or https:// URL")
+	}
+	if strings.Trim(parsed.Path, "/") != "" || parsed.RawQuery != "" {
+		return "", false, fmt.Errorf("host must not have a path or query")
+	}
+	address := parsed.Host
+	if parsed.Port() == "" {
+		port := "80"
+		if parsed.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(parsed.Hostname(), port)
+	}
+	return address, parsed.Scheme == "https", nil
+}
+
+// splitGRPCMethod splits package.Service/Method, with or without a leading
+// slash, into the service and method names.
+func splitGRPCMethod(method string) (service, name string, err error) {
+	service, name, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	if !ok || service == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("method must be package.Service/Method")
+	}
+	if !protoreflect.FullName(service).IsValid() || !protoreflect.Name(name).IsValid() {
+		return "", "", fmt.Errorf("invalid method name %q", method)
+	}
+	return service, name, nil
+}
+
+// validateGRPCConfig checks a gRPC test's settings against its host.
+func validateGRPCConfig(cfg *GRPCConfig, host string) error {
+	if _, _, err := grpcTarget(host); err != nil {
+		return err
+	}
+	if _, _, err := splitGRPCMethod(cfg.Method); err != nil {
+		return err
+	}
+	cfg.Method = strings.TrimPrefix(cfg.Method, "/")
+	if cfg.Payload == "" {
+		cfg.Payload = "{}"
+	}
+	if len(cfg.Payload) > MaxGRPCPayloadBytes {
+		return fmt.Errorf("payload must be at most %d bytes", MaxGRPCPayloadBytes)
+	}
+	if _, err := parseTemplate(cfg.Payload); err != nil {
+		return fmt.Errorf("payload: %v", err)
+	}
+	if base64.StdEncoding.DecodedLen(len(cfg.DescriptorSet)) > MaxGRPCDescriptorSetBytes {
+		return fmt.Errorf("descriptor_set must be at most %d bytes", MaxGRPCDescriptorSetBytes)
+	}
+	if cfg.Connections == 0 {
+		cfg.Connections = 1
+	}
+	if cfg.Connections < 0 || cfg.Connections > MaxGRPCConnections {
+		return fmt.Errorf("connections must be between 1 and %d", MaxGRPCConnections)
+	}
+	return nil
+}
+
+// tlsRecordingCreds counts the TLS handshakes of a gRPC connection in the
+// test's collector, like the trace of an HTTP request.
+type tlsRecordingCreds struct {
+	credentials.TransportCredentials
+	metrics *MetricsCollector
+}
+
+func (c tlsRecordingCreds) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, info, err := c.TransportCredentials.ClientHandshake(ctx, authority, rawConn)
+	if tlsInfo, ok := info.(credentials.TLSInfo); ok && err == nil {
+		c.metrics.RecordTLSSession(tlsInfo.State.Version, tlsInfo.State.CipherSuite)
+	}
+	return conn, info, err
+}
+
+func (c tlsRecordingCreds) Clone() credentials.TransportCredentials {
+	return tlsRecordingCreds{TransportCredentials: c.TransportCredentials.Clone(), metrics: c.metrics}
+}
+
+// newGRPCConn builds a connection shared by a gRPC test's users, with the
+// transport's connect timeout and TLS settings. Handshakes are recorded in
+// metrics when it is not nil.
+func newGRPCConn(host string, cfg TransportConfig, creds *clientTLS, metrics *MetricsCollector) (*grpc.ClientConn, error) {
+	address, secure, err := grpcTarget(host)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(cfg.ConnectTimeoutMs) * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transportCreds := insecure.NewCredentials()
+	if secure {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+			ServerName:         cfg.ServerName,
+		}
+		creds.apply(tlsConfig)
+		transportCreds = credentials.NewTLS(tlsConfig)
+		if metrics != nil {
+			transportCreds = tlsRecordingCreds{TransportCredentials: transportCreds, metrics: metrics}
+		}
+	}
+
+	return grpc.NewClient(address,
+		grpc.WithTransportCredentials(transportCreds),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", addr)
+		}),
+	)
+}
+
+// newGRPCCall connects the users of a gRPC test to its host and compiles the
+// payload of the resolved method. Connections are made by the first call
+// that uses them.
+func newGRPCCall(cfg *GRPCConfig, method protoreflect.MethodDescriptor, host string, transport TransportConfig, creds *clientTLS, metrics *MetricsCollector) (*grpcCall, error) {
+	payload, err := parseTemplate(cfg.Payload)
+	if err != nil {
+		return nil, err
+	}
+	call := &grpcCall{
+		fullMethod: "/" + string(method.Parent().FullName()) + "/" + string(method.Name()),
+		method:     method,
+		payload:    payload,
+	}
+	for i := 0; i < max(cfg.Connections, 1); i++ {
+		conn, err := newGRPCConn(host, transport, creds, metrics)
+		if err != nil {
+			call.close()
+			return nil, err
+		}
+		call.conns = append(call.conns, conn)
+	}
+	return call, nil
+}
+
+// conn returns the connection a user's calls are made on.
+func (call *grpcCall) conn(user *virtualUser) *grpc.ClientConn {
+	return call.conns[user.id%int64(len(call.conns))]
+}
+
+// close closes the test's connections.
+func (call *grpcCall) close() {
+	for _, conn := range call.conns {
+		conn.Close()
+	}
+}
+
+// resolveGRPCMethod finds the descriptor of the test's method, from the
+// uploaded descriptor set or else by server reflection, and checks the
+// payload against its request type when the payload has no templates. It sets
+// cfg.Schema and cfg.ServerStreaming.
+func resolveGRPCMethod(ctx context.Context, cfg *GRPCConfig, host string, transport TransportConfig, creds *clientTLS) (protoreflect.MethodDescriptor, error) {
+	serviceName, methodName, err := splitGRPCMethod(cfg.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	var files *protoregistry.Files
+	if cfg.DescriptorSet != "" {
+		cfg.Schema = GRPCSchemaDescriptorSet
+		if files, err = parseDescriptorSet(cfg.DescriptorSet); err != nil {
+			return nil, err
+		}
+	} else {
+		cfg.Schema = GRPCSchemaReflection
+		conn, err := newGRPCConn(host, transport, creds, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(ctx, grpcReflectionTimeout)
+		defer cancel()
+		if files, err = reflectFiles(ctx, conn, serviceName); err != nil {
+			return nil, fmt.Errorf("server reflection: %v", err)
+		}
+	}
+
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("service %s not found", serviceName)
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", serviceName)
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, fmt.Errorf("method %s not found in service %s", methodName, serviceName)
+	}
+	if method.IsStreamingClient() {
+		return nil, fmt.Errorf("client-streaming and bidirectional methods are not supported")
+	}
+	cfg.ServerStreaming = method.IsStreamingServer()
+
+	if !strings.Contains(cfg.Payload, "{{") {
+		if err := protojson.Unmarshal([]byte(cfg.Payload), dynamicpb.NewMessage(method.Input())); err != nil {
+			return nil, fmt.Errorf("payload is not a valid %s: %v", method.Input().FullName(), err)
+		}
+	}
+	return method, nil
+}
+
+// parseDescriptorSet decodes a base64 FileDescriptorSet, as written by
+// protoc --include_imports --descriptor_set_out.
+func parseDescriptorSet(encoded string) (*protoregistry.Files, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("descriptor_set is not base64: %v", err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor_set: %v", err)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor_set: %v", err)
+	}
+	return files, nil
+}
+
+// reflectionQuery asks a reflection service for the file defining a symbol,
+// or for a file by name, returning the serialized files it sends back.
+type reflectionQuery func(symbol, filename string) ([][]byte, error)
+
+// reflectFiles fetches the file defining service and its dependencies by
+// server reflection, trying v1 first and falling back to v1alpha.
+func reflectFiles(ctx context.Context, conn *grpc.ClientConn, service string) (*protoregistry.Files, error) {
+	query, err := reflectionV1(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	response, err := query(service, "")
+	if status.Code(err) == codes.Unimplemented {
+		if query, err = reflectionV1Alpha(ctx, conn); err != nil {
+			return nil, err
+		}
+		response, err = query(service, "")
+	}
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.Unimplemented:
+		return nil, fmt.Errorf("the target does not support it, upload a descriptor_set instead")
+	case codes.NotFound:
+		return nil, fmt.Errorf("service %s not found", service)
+	default:
+		return nil, err
+	}
+
+	// Servers usually send the dependencies along; fetch any they left out
+	fileProtos := map[string]*descriptorpb.FileDescriptorProto{}
+	for len(response) > 0 {
+		var missing []string
+		for _, raw := range response {
+			file := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, file); err != nil {
+				return nil, err
+			}
+			fileProtos[file.GetName()] = file
+		}
+		for _, file := range fileProtos {
+			for _, dependency := range file.GetDependency() {
+				if fileProtos[dependency] == nil {
+					missing = append(missing, dependency)
+				}
+			}
+		}
+		response = nil
+		for _, name := range missing {
+			if fileProtos[name] != nil {
+				continue
+			}
+			files, err := query("", name)
+			if err != nil {
+				return nil, err
+			}
+			response = append(response, files...)
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range fileProtos {
+		set.File = append(set.File, file)
+	}
+	return protodesc.NewFiles(set)
+}
+
+func reflectionV1(ctx context.Context, conn *grpc.ClientConn) (reflectionQuery, error) {
+	stream, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func(symbol, filename string) ([][]byte, error) {
+		req := &reflectionv1.ServerReflectionRequest{}
+		if symbol != "" {
+			req.MessageRequest = &reflectionv1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol}
+		} else {
+			req.MessageRequest = &reflectionv1.ServerReflectionRequest_FileByFilename{FileByFilename: filename}
+		}
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if failed := resp.GetErrorResponse(); failed != nil {
+			return nil, status.Error(codes.Code(failed.GetErrorCode()), failed.GetErrorMessage())
+		}
+		return resp.GetFileDescriptorResponse().GetFileDescriptorProto(), nil
+	}, nil
+}
+
+func reflectionV1Alpha(ctx context.Context, conn *grpc.ClientConn) (reflectionQuery, error) {
+	stream, err := reflectionv1alpha.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func(symbol, filename string) ([][]byte, error) {
+		req := &reflectionv1alpha.ServerReflectionRequest{}
+		if symbol != "" {
+			req.MessageRequest = &reflectionv1alpha.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol}
+		} else {
+			req.MessageRequest = &reflectionv1alpha.ServerReflectionRequest_FileByFilename{FileByFilename: filename}
+		}
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if failed := resp.GetErrorResponse(); failed != nil {
+			return nil, status.Error(codes.Code(failed.GetErrorCode()), failed.GetErrorMessage())
+		}
+		return resp.GetFileDescriptorResponse().GetFileDescriptorProto(), nil
+	}, nil
+}
+
+// invokeGRPC makes one call of a gRPC test's method and records it like
+// executeRequest records a request, with the gRPC status code in place of
+// the HTTP status.
+func (tm *TestManager) invokeGRPC(ctx context.Context, testCtx *TestContext, user *virtualUser, intendedStart time.Time) {
+	metrics := testCtx.Metrics
+	call := testCtx.GRPC
+	start := time.Now()
+	if intendedStart.After(start) {
+		intendedStart = start
+	}
+
+	// A call whose payload or metadata cannot be built fails without a gRPC
+	// status, but is recorded like any other
+	var header http.Header
+	var received int64
+	called := false
+	errorCategory := ""
+	in := dynamicpb.NewMessage(call.method.Input())
+	err := protojson.Unmarshal([]byte(call.payload.render(user)), in)
+	if err != nil {
+		errorCategory = ErrorCategoryRequestError
+		err = fmt.Errorf("payload is not a valid %s: %v", call.method.Input().FullName(), err)
+	} else if header, errorCategory, err = grpcHeader(ctx, testCtx, user); err == nil {
+		// Token requests have their own metrics, so the call's latency starts
+		// once its metadata is ready
+		start = time.Now()
+		called = true
+		received, err = call.invoke(ctx, call.conn(user), in, header, testCtx.TestRun.Transport.RequestTimeout())
+	}
+	completedAt := time.Now()
+	latency := completedAt.Sub(start).Seconds() * 1000
+	correctedLatency := completedAt.Sub(intendedStart).Seconds() * 1000
+
+	success := err == nil
+	code := status.Code(err)
+	grpcStatus := ""
+	if called {
+		grpcStatus = grpcCodeName(code)
+		if !success {
+			errorCategory = grpcErrorCategory(code)
+			if ctx.Err() != nil {
+				errorCategory = ErrorCategoryContextCancelled
+			}
+		}
+		if code == codes.Unauthenticated {
+			// A rejected token is fetched again on the next call
+			if tokens := testCtx.tokenSourceFor(user); tokens != nil {
+				tokens.invalidate(strings.TrimPrefix(header.Get("Authorization"), "Bearer "))
+			}
+		}
+	}
+
+	metrics.RecordWithoutStatus(latency, correctedLatency, success)
+	if called {
+		metrics.RecordGRPC(code, received)
+	}
+	if !success {
+		metrics.RecordError(errorCategory, 0, errorSample(err))
+	}
+	testCtx.Writer.Write(&RequestMetric{
+		TestRunID:        testCtx.TestRun.ID,
+		Timestamp:        completedAt,
+		Latency:          latency,
+		CorrectedLatency: correctedLatency,
+		Success:          success,
+		ErrorCategory:    errorCategory,
+		GRPCStatus:       grpcStatus,
+	})
+}
+
+// invoke sends one call on conn with header as its metadata, reading every
+// response of a server-streaming method. It returns the number of stream
+// responses.
+func (call *grpcCall) invoke(ctx context.Context, conn *grpc.ClientConn, in proto.Message, header http.Header, timeout time.Duration) (int64, error) {
+	md := metadata.MD{}
+	for key, values := range header {
+		md.Append(key, values...)
+	}
+	ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(ctx, md), timeout)
+	defer cancel()
+
+	if call.method.IsStreamingServer() {
+		return call.stream(ctx, conn, in)
+	}
+	return 0, conn.Invoke(ctx, call.fullMethod, in, dynamicpb.NewMessage(call.method.Output()))
+}
+
+// stream makes a server-streaming call, reading every response until the
+// server ends the stream. It returns the number of responses.
+func (call *grpcCall) stream(ctx context.Context, conn *grpc.ClientConn, in proto.Message) (int64, error) {
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, call.fullMethod)
+	if err != nil {
+		return 0, err
+	}
+	if err := stream.SendMsg(in); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return 0, err
+	}
+	var received int64
+	for {
+		if err := stream.RecvMsg(dynamicpb.NewMessage(call.method.Output())); err != nil {
+			if errors.Is(err, io.EOF) {
+				return received, nil
+			}
+			return received, err
+		}
+		received++
+	}
+}
+
+// grpcHeader builds the metadata of a call from the test's headers and
+// authentication. On failure it returns the error category.
+func grpcHeader(ctx context.Context, testCtx *TestContext, user *virtualUser) (http.Header, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, testCtx.Steps[0].URL, nil)
+	if err != nil {
+		return nil, ErrorCategoryRequestError, err
+	}
+	for key, value := range testCtx.Headers {
+		req.Header.Set(key, value.render(user))
+	}
+	applyAuth(req, testCtx.AuthConfig)
+	if tokens := testCtx.tokenSourceFor(user); tokens != nil {
+		token, err := tokens.Token(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, classifyError(err), err
+			}
+			return nil, ErrorCategoryTokenError, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req.Header, "", nil
+}
+
+// grpcCodeName returns the canonical name of a status code.
+func grpcCodeName(code codes.Code) string {
+	if name, ok := grpcCodeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("CODE_%d", code)
+}
+
+// grpcErrorCategory is the error category of a failed call, the status code
+// name prefixed with grpc_, e.g. grpc_unavailable.
+func grpcErrorCategory(code codes.Code) string {
+	return "grpc_" + strings.ToLower(grpcCodeName(code))
+}
+
+// RecordGRPC counts a gRPC call by status code, and the responses of a
+// server-streaming call.
+func (mc *MetricsCollector) RecordGRPC(code codes.Code, streamMessages int64) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.grpc == nil {
+		mc.grpc = &grpcCollector{codes: make(map[codes.Code]int64)}
+	}
+	mc.grpc.codes[code]++
+	mc.grpc.streamMessages += streamMessages
+}
+
+// GRPCMetrics summarises the calls so far, or returns nil for tests without
+// gRPC calls.
+func (mc *MetricsCollector) GRPCMetrics() *GRPCMetrics {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	if mc.grpc == nil {
+		return nil
+	}
+	metrics := &GRPCMetrics{StatusCodes: make(map[string]int64, len(mc.grpc.codes)), StreamMessages: mc.grpc.streamMessages}
+	for code, count := range mc.grpc.codes {
+		metrics.StatusCodes[grpcCodeName(code)] += count
+		metrics.Calls += count
+	}
+	return metrics
+}
+
+// sortedGRPCCodes returns the status code names of a summary, most frequent
+// first.
+func sortedGRPCCodes(counts map[string]int64) []string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
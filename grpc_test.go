@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// startHealthServer serves the standard health service in-process, with
+// server reflection when reflect is set, and returns its http:// host.
+func startHealthServer(t *testing.T, reflect bool) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("up", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	if reflect {
+		reflection.Register(srv)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	return "http://" + ln.Addr().String()
+}
+
+// healthDescriptorSet returns the health service's FileDescriptorSet, base64
+// encoded as it is uploaded.
+func healthDescriptorSet(t *testing.T) string {
+	file := protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(set)
+}
+
+// newTestGRPCCall resolves cfg's method against host and connects to it.
+func newTestGRPCCall(t *testing.T, cfg *GRPCConfig, host string) *grpcCall {
+	method, err := resolveGRPCMethod(context.Background(), cfg, host, TransportConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, err := newGRPCCall(cfg, method, host, TransportConfig{}, nil, NewMetricsCollector(DefaultHistogramPrecision))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(call.close)
+	return call
+}
+
+// grpcRequest builds a call's input from a JSON payload.
+func grpcRequest(t *testing.T, call *grpcCall, payload string) proto.Message {
+	in := dynamicpb.NewMessage(call.method.Input())
+	if err := protojson.Unmarshal([]byte(payload), in); err != nil {
+		t.Fatal(err)
+	}
+	return in
+}
+
+func TestGRPCUnaryCall(t *testing.T) {
+	for _, schema := range []string{GRPCSchemaReflection, GRPCSchemaDescriptorSet} {
+		t.Run(schema, func(t *testing.T) {
+			cfg := &GRPCConfig{Method: "grpc.health.v1.Health/Check", Payload: `{"service":"up"}`}
+			host := startHealthServer(t, true)
+			if schema == GRPCSchemaDescriptorSet {
+				cfg.DescriptorSet = healthDescriptorSet(t)
+				host = startHealthServer(t, false)
+			}
+			call := newTestGRPCCall(t, cfg, host)
+			if cfg.Schema != schema || cfg.ServerStreaming {
+				t.Fatalf("schema = %q, streaming = %v, want %q unary", cfg.Schema, cfg.ServerStreaming, schema)
+			}
+			if call.fullMethod != "/grpc.health.v1.Health/Check" {
+				t.Errorf("full method = %q", call.fullMethod)
+			}
+
+			header := http.Header{"X-Test": {"1"}}
+			received, err := call.invoke(context.Background(), call.conns[0], grpcRequest(t, call, `{"service":"up"}`), header, time.Second)
+			if err != nil || received != 0 {
+				t.Errorf("invoke() = %d, %v, want a unary OK", received, err)
+			}
+			_, err = call.invoke(context.Background(), call.conns[0], grpcRequest(t, call, `{"service":"down"}`), header, time.Second)
+			if code := status.Code(err); code != codes.NotFound {
+				t.Errorf("code = %v for an unknown service, want NotFound", code)
+			}
+		})
+	}
+}
+
+func TestGRPCServerStreamingCall(t *testing.T) {
+	for _, schema := range []string{GRPCSchemaReflection, GRPCSchemaDescriptorSet} {
+		t.Run(schema, func(t *testing.T) {
+			cfg := &GRPCConfig{Method: "grpc.health.v1.Health/Watch", Payload: `{"service":"up"}`}
+			if schema == GRPCSchemaDescriptorSet {
+				cfg.DescriptorSet = healthDescriptorSet(t)
+			}
+			call := newTestGRPCCall(t, cfg, startHealthServer(t, schema == GRPCSchemaReflection))
+			if !cfg.ServerStreaming {
+				t.Fatal("Watch is not resolved as server-streaming")
+			}
+
+			// Watch sends the current status and stays open until the call
+			// times out
+			received, err := call.invoke(context.Background(), call.conns[0], grpcRequest(t, call, `{"service":"up"}`), nil, 200*time.Millisecond)
+			if code := status.Code(err); code != codes.DeadlineExceeded {
+				t.Errorf("code = %v, want DeadlineExceeded", code)
+			}
+			if received != 1 {
+				t.Errorf("received %d stream messages, want 1", received)
+			}
+		})
+	}
+}
+
+func TestGRPCConnections(t *testing.T) {
+	cfg := &GRPCConfig{Method: "grpc.health.v1.Health/Check", Payload: `{"service":"up"}`, Connections: 3}
+	call := newTestGRPCCall(t, cfg, startHealthServer(t, true))
+	if len(call.conns) != 3 {
+		t.Fatalf("%d connections, want 3", len(call.conns))
+	}
+
+	// Users are spread over the connections, and keep theirs
+	for id := int64(1); id <= 3; id++ {
+		user := &virtualUser{id: id}
+		if call.conn(user) != call.conns[id%3] || call.conn(&virtualUser{id: id + 3}) != call.conn(user) {
+			t.Errorf("user %d is not on connection %d", id, id%3)
+		}
+		received, err := call.invoke(context.Background(), call.conn(user), grpcRequest(t, call, `{"service":"up"}`), nil, time.Second)
+		if err != nil || received != 0 {
+			t.Errorf("invoke() = %d, %v on connection %d", received, err, id%3)
+		}
+	}
+}
+
+func TestResolveGRPCMethodErrors(t *testing.T) {
+	reflecting := startHealthServer(t, true)
+	bare := startHealthServer(t, false)
+	for _, c := range []struct {
+		name string
+		cfg  GRPCConfig
+		host string
+	}{
+		{"unknown method", GRPCConfig{Method: "grpc.health.v1.Health/Nope"}, reflecting},
+		{"unknown service", GRPCConfig{Method: "x.Y/Z"}, reflecting},
+		{"invalid payload", GRPCConfig{Method: "grpc.health.v1.Health/Check", Payload: `{"bogus":1}`}, reflecting},
+		{"no reflection", GRPCConfig{Method: "grpc.health.v1.Health/Check", Payload: "{}"}, bare},
+		{"invalid descriptor set", GRPCConfig{Method: "grpc.health.v1.Health/Check", DescriptorSet: "!!"}, bare},
+	} {
+		if _, err := resolveGRPCMethod(context.Background(), &c.cfg, c.host, TransportConfig{}, nil); err == nil {
+			t.Errorf("%s: resolveGRPCMethod() succeeded", c.name)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	DefaultHistogramPrecision = 3 // Significant decimal digits kept by latency histograms
+	MinHistogramPrecision     = 1
+	MaxHistogramPrecision     = 3 // A histogram takes about 3.5 KB at 1 digit, 25 KB at 2 and 170 KB at 3; 4 would take 2.2 MB
+
+	// histogramMaxLatency is the slowest latency a histogram can hold; slower
+	// samples are recorded at this value.
+	histogramMaxLatency = 10 * time.Minute
+)
+
+// LatencyStats summarises a latency distribution in milliseconds.
+type LatencyStats struct {
+	Count int64   `json:"count"`
+	Avg   float64 `json:"avg"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	P999  float64 `json:"p999"`
+}
+
+// newLatencyHistogram creates a histogram of latencies in microseconds that
+// keeps the given number of significant digits over the whole test.
+func newLatencyHistogram(precision int) *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, histogramMaxLatency.Microseconds(), precision)
+}
+
+// recordLatency adds a latency in milliseconds to the histogram.
+func recordLatency(h *hdrhistogram.Histogram, latencyMs float64) {
+	micros := int64(math.Round(latencyMs * 1000))
+	if micros < 0 {
+		micros = 0
+	}
+	if micros > h.HighestTrackableValue() {
+		micros = h.HighestTrackableValue()
+	}
+	// The value is clamped to the trackable range, so this cannot fail
+	_ = h.RecordValue(micros)
+}
+
+// histogramStats summarises a latency histogram in milliseconds.
+func histogramStats(h *hdrhistogram.Histogram) LatencyStats {
+	if h == nil || h.TotalCount() == 0 {
+		return LatencyStats{}
+	}
+
+	toMs := func(micros int64) float64 { return float64(micros) / 1000 }
+	return LatencyStats{
+		Count: h.TotalCount(),
+		Avg:   h.Mean() / 1000,
+		Min:   toMs(h.Min()),
+		Max:   toMs(h.Max()),
+		P50:   toMs(h.ValueAtQuantile(50)),
+		P90:   toMs(h.ValueAtQuantile(90)),
+		P95:   toMs(h.ValueAtQuantile(95)),
+		P99:   toMs(h.ValueAtQuantile(99)),
+		P999:  toMs(h.ValueAtQuantile(99.9)),
+	}
+}
+
+// encodeHistogram serializes a histogram in the standard compressed,
+// base64-encoded HdrHistogram format.
+func encodeHistogram(h *hdrhistogram.Histogram) (string, error) {
+	encoded, err := h.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func decodeHistogram(encoded string) (*hdrhistogram.Histogram, error) {
+	return hdrhistogram.Decode([]byte(encoded))
+}
+
+// computeLatencyStats sorts values in place and summarises them. It is used
+// for tests recorded before latency histograms were persisted.
+func computeLatencyStats(values []float64) LatencyStats {
+	stats := LatencyStats{Count: int64(len(values))}
+	if len(values) == 0 {
+		return stats
+	}
+
+	sort.Float64s(values)
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	stats.Avg = sum / float64(len(values))
+	stats.Min = values[0]
+	stats.Max = values[len(values)-1]
+	stats.P50 = nearestRank(values, 0.50)
+	stats.P90 = nearestRank(values, 0.90)
+	stats.P95 = nearestRank(values, 0.95)
+	stats.P99 = nearestRank(values, 0.99)
+	stats.P999 = nearestRank(values, 0.999)
+
+	return stats
+}
+
+func nearestRank(sorted []float64, percentile float64) float64 {
+	index := int(float64(len(sorted)) * percentile)
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
@@ -9,15 +9,18 @@ import (
 	"io"
 	"log"
 	"log/slog"
+	"math"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
-	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/google/uuid"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // parseJSON is a helper function to parse JSON from request body
@@ -46,37 +49,118 @@ type TestContext struct {
 	Metrics    *MetricsCollector
 	IsRunning  *atomic.Bool
 	AuthConfig *AuthConfig
-	Method     string
-	Body       string
-	Headers    map[string]string
+	Steps      []ScenarioStep              // Requests of one iteration; a single unnamed step without a scenario
+	Mix        *requestPicker              // Set for a request mix: each iteration sends one of Steps
+	Data       *dataFeeder                 // Rows drawn by each iteration, nil without test data
+	Cookies    *CookieConfig               // Cookie jar settings including seed values, nil without cookies
+	Tokens     *tokenSource                // OAuth2 tokens shared by the test, or the template of per-user sources
+	Headers    map[string]*requestTemplate // Sent with every step
+	Writer     *MetricWriter               // Persists request metrics; closed before final metrics are saved
+	Transport  http.RoundTripper           // Shared by the test's users, built from TestRun.Transport
+	WebSocket  *WebSocketConfig            // Set for a WebSocket test: users hold connections instead of running iterations
+	GRPC       *grpcCall                   // Set for a gRPC test: every request is a call of its method
+	Socket     *socketTest                 // Set for a socket test: every request is written to a TCP or UDP socket
+	UserIDs    atomic.Int64                // Last virtual user id handed out
+	Sequence   atomic.Int64                // Counter behind the {{sequence}} template function
 }
 
 type AuthConfig struct {
-	Type        string            `json:"type"`         // "jwt", "basic", "header"
+	Type        string            `json:"type"`         // "jwt", "basic", "header", "oauth2_client_credentials", "oauth2_password", "hmac", "aws_sigv4"
 	Token       string            `json:"token"`        // For JWT
-	Username    string            `json:"username"`     // For Basic Auth
-	Password    string            `json:"password"`     // For Basic Auth
+	Username    string            `json:"username"`     // For Basic Auth and the OAuth2 password grant
+	Password    string            `json:"password"`     // For Basic Auth and the OAuth2 password grant
 	HeaderName  string            `json:"header_name"`  // For custom header
 	HeaderValue string            `json:"header_value"` // For custom header
 	Headers     map[string]string `json:"headers"`      // For multiple custom headers
+
+	// For OAuth2
+	TokenURL         string   `json:"token_url,omitempty"`
+	ClientID         string   `json:"client_id,omitempty"`
+	ClientSecret     string   `json:"client_secret,omitempty"`
+	Scopes           []string `json:"scopes,omitempty"`
+	Audience         string   `json:"audience,omitempty"`
+	ClientAuthInBody bool     `json:"client_auth_in_body,omitempty"` // Send client credentials as form fields instead of Basic auth
+	TokenCache       string   `json:"token_cache,omitempty"`         // "test" (default) or "user"
+
+	// For HMAC signing
+	HMACSecret        string `json:"hmac_secret,omitempty"`
+	HMACKeyID         string `json:"hmac_key_id,omitempty"`        // Sent in KeyIDHeader when set
+	SignatureHeader   string `json:"signature_header,omitempty"`   // Default X-Signature
+	TimestampHeader   string `json:"timestamp_header,omitempty"`   // Default X-Timestamp
+	KeyIDHeader       string `json:"key_id_header,omitempty"`      // Default X-Key-Id
+	SignatureEncoding string `json:"signature_encoding,omitempty"` // "hex" (default) or "base64"
+
+	// For AWS Signature Version 4
+	AWSAccessKeyID     string `json:"aws_access_key_id,omitempty"`
+	AWSSecretAccessKey string `json:"aws_secret_access_key,omitempty"`
+	AWSSessionToken    string `json:"aws_session_token,omitempty"` // For temporary credentials
+	AWSRegion          string `json:"aws_region,omitempty"`
+	AWSService         string `json:"aws_service,omitempty"` // Default execute-api
 }
 
 type MetricsCollector struct {
-	TotalRequests int64
-	SuccessCount  int64
-	ErrorCount    int64
-	Latencies     []float64
-	TimeSeries    []TimeSeriesPoint
-	mu            sync.RWMutex
-	StartTime     time.Time
+	TotalRequests       int64
+	SuccessCount        int64
+	ErrorCount          int64
+	DroppedIterations   int64         // Arrivals skipped because the worker pool was exhausted
+	SessionsEstablished int64         // Users whose cookie jar received a cookie from the target
+	ActiveUsers         int64         // Running users (closed loop) or busy workers (arrival rate)
+	targetLoad          atomic.Uint64 // float64 bits of the load profile's current target
+	// Latencies holds the service time (send to response) of every request in
+	// the test. CorrectedLatencies measures each request from its intended
+	// send time, so time spent queued behind a slow response is not omitted.
+	Latencies          *hdrhistogram.Histogram
+	CorrectedLatencies *hdrhistogram.Histogram
+	intervalLatency    float64 // Sum of service times since the last time-series point
+	intervalCount      int64
+	// Phase timings are summed over requests that received a response
+	phaseSum           PhaseTimings
+	phaseCount         int64
+	intervalPhaseSum   PhaseTimings
+	intervalPhaseCount int64
+	errors             ErrorBreakdown
+	statusCodes        map[int]int64 // Requests per status code, 0 for no response
+	steps              map[string]*stepCollector
+	tokens             *tokenCollector         // OAuth2 token endpoint requests, nil until the first
+	tlsSessions        map[tlsSessionKey]int64 // Handshakes per negotiated version and cipher suite
+	websocket          *webSocketCollector     // Connections and messages of a WebSocket test, nil until the first
+	grpc               *grpcCollector          // Status codes of a gRPC test's calls, nil until the first
+	graphql            *graphQLCollector       // Requests per GraphQL operation, nil until the first
+	stream             *streamCollector        // Streams of a stream test, nil until the first
+	socket             *socketCollector        // Connections and bytes of a socket test, nil until the first
+	precision          int                     // Significant digits of the latency histograms
+	intervalStatus     StatusClassCounts
+	TimeSeries         []TimeSeriesPoint
+	mu                 sync.RWMutex
+	StartTime          time.Time
+}
+
+// NewMetricsCollector creates a collector whose latency histograms keep the
+// given number of significant digits.
+func NewMetricsCollector(precision int) *MetricsCollector {
+	return &MetricsCollector{
+		StartTime:          time.Now(),
+		Latencies:          newLatencyHistogram(precision),
+		CorrectedLatencies: newLatencyHistogram(precision),
+		errors:             newErrorBreakdown(),
+		statusCodes:        make(map[int]int64),
+		steps:              make(map[string]*stepCollector),
+		precision:          precision,
+		TimeSeries:         make([]TimeSeriesPoint, 0),
+	}
 }
 
 type TimeSeriesPoint struct {
-	Timestamp   time.Time `json:"timestamp"`
-	Requests    int64     `json:"requests"`
-	RPS         float64   `json:"rps"`
-	AvgLatency  float64   `json:"avg_latency"`
-	SuccessRate float64   `json:"success_rate"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Requests    int64        `json:"requests"`
+	RPS         float64      `json:"rps"`
+	AvgLatency  float64      `json:"avg_latency"`
+	SuccessRate float64      `json:"success_rate"`
+	ActiveUsers int64        `json:"active_users"`
+	TargetLoad  float64      `json:"target_load"`
+	Phases      PhaseTimings `json:"phases"` // Average phase timings in this interval
+	// Requests completed in this interval by status class
+	StatusClasses StatusClassCounts `json:"status_classes"`
 }
 
 func NewTestManager(db *sql.DB) *TestManager {
@@ -127,16 +211,24 @@ func (tm *TestManager) Shutdown() {
 }
 
 const (
-	MaxUsers           = 1000  // Maximum concurrent users per test
-	MaxDuration        = 300   // Maximum duration in seconds (5 minutes)
-	MaxRampUpSec       = 300   // Maximum ramp-up time in seconds
-	MinUsers           = 1     // Minimum users
-	MinDuration        = 1     // Minimum duration in seconds
-	MinRampUpSec       = 0     // Minimum ramp-up time in seconds (0 = start all users immediately)
-	MaxConcurrentTests = 50    // Maximum concurrent active tests (prevents resource exhaustion)
-	MaxTestsPerIP      = 3     // Maximum concurrent tests per IP address (prevents abuse)
-	MaxLatencySamples  = 10000 // Maximum latency samples to keep in memory per test
-	RateLimitSeconds   = 5     // Minimum seconds between test starts per IP
+	MaxUsers           = 1000 // Maximum concurrent users per test
+	MaxDuration        = 300  // Maximum duration in seconds (5 minutes)
+	MaxRampUpSec       = 300  // Maximum ramp-up time in seconds
+	MinUsers           = 1    // Minimum users
+	MinDuration        = 1    // Minimum duration in seconds
+	MinRampUpSec       = 0    // Minimum ramp-up time in seconds (0 = start all users immediately)
+	MaxConcurrentTests = 50   // Maximum concurrent active tests (prevents resource exhaustion)
+	MaxTestsPerIP      = 3    // Maximum concurrent tests per IP address (prevents abuse)
+	RateLimitSeconds   = 5    // Minimum seconds between test starts per IP
+	MaxTargetRPS       = 5000 // Maximum offered load for the arrival-rate executor
+)
+
+// Executors understood by /api/start. The closed loop runs a fixed number of
+// users that each wait for their previous response; the arrival-rate executor
+// starts iterations at a fixed rate regardless of how the target responds.
+const (
+	ExecutorClosedLoop  = "closed_loop"
+	ExecutorArrivalRate = "arrival_rate"
 )
 
 func (tm *TestManager) HandleStartTest(w http.ResponseWriter, r *http.Request) {
@@ -157,24 +249,87 @@ func (tm *TestManager) HandleStartTest(w http.ResponseWriter, r *http.Request) {
 		Headers               map[string]string `json:"headers,omitempty"`                 // Custom headers
 		MaxConcurrentRequests int               `json:"max_concurrent_requests,omitempty"` // Max concurrent requests per user (default: 10)
 		ErrorThreshold        float64           `json:"error_threshold,omitempty"`         // Error rate % to trigger circuit breaker (default: 0 = disabled)
-	}
-
+		Executor              string            `json:"executor,omitempty"`                // "closed_loop" (default) or "arrival_rate"
+		TargetRPS             float64           `json:"target_rps,omitempty"`              // Offered load for the arrival-rate executor
+		Stages                []Stage           `json:"stages,omitempty"`                  // Optional multi-stage load profile
+		HistogramPrecision    int               `json:"histogram_precision,omitempty"`     // Significant digits kept by latency histograms (default: 3)
+		Transport             *TransportConfig  `json:"transport,omitempty"`               // Connection, HTTP/2 and timeout settings
+		Scenario              []ScenarioStep    `json:"scenario,omitempty"`                // Ordered steps run on every iteration instead of a single request
+		RequestMix            []ScenarioStep    `json:"request_mix,omitempty"`             // Weighted requests, one picked per iteration
+		Assertions            []Assertion       `json:"assertions,omitempty"`              // Success checks for a single-request test
+		Data                  *DataConfig       `json:"data,omitempty"`                    // CSV or JSONL rows usable as template variables
+		ThinkTime             *ThinkTime        `json:"think_time,omitempty"`              // Pause after each request unless its step sets one
+		PacingMs              int               `json:"pacing_ms,omitempty"`               // Fixed iteration duration for closed-loop users
+		Cookies               *CookieConfig     `json:"cookies,omitempty"`                 // Per-user cookie jars
+		Redirects             *RedirectConfig   `json:"redirects,omitempty"`               // Redirect policy (default: follow up to 10)
+		TLS                   *ClientTLSConfig  `json:"tls,omitempty"`                     // Client certificate and CA bundle for mutual TLS
+		WebSocket             *WebSocketConfig  `json:"websocket,omitempty"`               // Hold connections to a ws:// or wss:// host instead of sending requests
+		GRPC                  *GRPCConfig       `json:"grpc,omitempty"`                    // Call a gRPC method of host instead of sending requests
+		GraphQL               *GraphQLRequest   `json:"graphql,omitempty"`                 // GraphQL operation sent as the single request
+		Stream                *StreamConfig     `json:"stream,omitempty"`                  // Read every response as a stream of events
+		Socket                *SocketConfig     `json:"socket,omitempty"`                  // Write to a TCP or UDP host:port instead of sending requests
+	}
+
+	// The body may carry a data file, so it is bounded by the data limit
+	r.Body = http.MaxBytesReader(w, r.Body, MaxDataBytes+1<<20)
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Validate host
-	if req.Host == "" {
+	// Validate host; a scenario or request mix carries its own URLs and only
+	// uses host to resolve relative ones
+	if req.Host == "" && len(req.Scenario) == 0 && len(req.RequestMix) == 0 {
 		http.Error(w, "Host is required", http.StatusBadRequest)
 		return
 	}
 
-	// Validate host for security (SSRF prevention)
-	if err := validateHost(req.Host); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid host: %v", err), http.StatusBadRequest)
+	// Validate host for security (SSRF prevention); a WebSocket host is
+	// checked as the HTTP URL its handshake is sent to
+	if isWebSocketURL(req.Host) != (req.WebSocket != nil) {
+		http.Error(w, "Invalid host: ws:// and wss:// hosts are only used by WebSocket tests, which require one", http.StatusBadRequest)
 		return
 	}
+	if req.Host != "" {
+		if err := validateHost(webSocketHTTPURL(req.Host)); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid host: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Validate executor; for the arrival-rate executor, users bounds the worker pool
+	if req.Executor == "" {
+		req.Executor = ExecutorClosedLoop
+	}
+	if req.Executor != ExecutorClosedLoop && req.Executor != ExecutorArrivalRate {
+		http.Error(w, fmt.Sprintf("Invalid executor. Allowed: %v", []string{ExecutorClosedLoop, ExecutorArrivalRate}), http.StatusBadRequest)
+		return
+	}
+
+	// A stage list replaces ramp_up_sec/duration and sets the peak load
+	if len(req.Stages) > 0 {
+		totalDuration, peak, err := validateStages(req.Stages, req.Executor)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid stages: %v", err), http.StatusBadRequest)
+			return
+		}
+		req.Duration = totalDuration
+		req.RampUpSec = 0
+		if req.Executor == ExecutorArrivalRate {
+			req.TargetRPS = peak
+		} else {
+			req.Users = int(math.Ceil(peak))
+		}
+	}
+
+	if req.Executor == ExecutorArrivalRate {
+		if req.TargetRPS <= 0 || req.TargetRPS > MaxTargetRPS {
+			http.Error(w, fmt.Sprintf("Target RPS must be greater than 0 and at most %d for the %s executor", MaxTargetRPS, ExecutorArrivalRate), http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.TargetRPS = 0
+	}
 
 	// Validate and enforce limits
 	if req.Users < MinUsers || req.Users > MaxUsers {
@@ -198,23 +353,22 @@ func (tm *TestManager) HandleStartTest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate HTTP method (default to GET if not specified)
-	if req.Method == "" {
-		req.Method = "GET"
+	// Validate latency histogram precision
+	if req.HistogramPrecision == 0 {
+		req.HistogramPrecision = DefaultHistogramPrecision
 	}
-	req.Method = strings.ToUpper(req.Method)
-	validMethods := []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"}
-	methodValid := false
-	for _, m := range validMethods {
-		if req.Method == m {
-			methodValid = true
-			break
-		}
+	if req.HistogramPrecision < MinHistogramPrecision || req.HistogramPrecision > MaxHistogramPrecision {
+		http.Error(w, fmt.Sprintf("Histogram precision must be between %d and %d significant digits", MinHistogramPrecision, MaxHistogramPrecision), http.StatusBadRequest)
+		return
 	}
-	if !methodValid {
+
+	// Validate HTTP method (default to GET if not specified)
+	method, err := validateMethod(req.Method)
+	if err != nil {
 		http.Error(w, fmt.Sprintf("Invalid HTTP method. Allowed: %v", validMethods), http.StatusBadRequest)
 		return
 	}
+	req.Method = method
 
 	// Validate body is only present for appropriate methods
 	if req.Body != "" && (req.Method == "GET" || req.Method == "HEAD") {
@@ -222,6 +376,259 @@ func (tm *TestManager) HandleStartTest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(req.Scenario) > 0 && len(req.RequestMix) > 0 {
+		http.Error(w, "Scenario and request mix cannot be combined", http.StatusBadRequest)
+		return
+	}
+	if len(req.Assertions) > 0 && (len(req.Scenario) > 0 || len(req.RequestMix) > 0) {
+		http.Error(w, "Assertions of a scenario or request mix are set on each step", http.StatusBadRequest)
+		return
+	}
+	if req.GraphQL != nil {
+		if len(req.Scenario) > 0 || len(req.RequestMix) > 0 {
+			http.Error(w, "GraphQL requests of a scenario or request mix are set on each step", http.StatusBadRequest)
+			return
+		}
+		if req.Body != "" || (req.Method != "GET" && req.Method != "POST") {
+			http.Error(w, "GraphQL requests are sent as a POST body, without method or body", http.StatusBadRequest)
+			return
+		}
+		req.Method = "POST"
+	}
+	if req.WebSocket != nil {
+		if req.Executor != ExecutorClosedLoop {
+			http.Error(w, fmt.Sprintf("WebSocket tests use the %s executor", ExecutorClosedLoop), http.StatusBadRequest)
+			return
+		}
+		if len(req.Scenario) > 0 || len(req.RequestMix) > 0 || len(req.Assertions) > 0 || req.Body != "" || req.Method != "GET" || req.GraphQL != nil || req.ThinkTime != nil || req.PacingMs > 0 {
+			http.Error(w, "WebSocket tests send websocket.messages instead of method, body, graphql, scenario, request_mix, assertions, think_time or pacing_ms", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.GRPC != nil {
+		if req.WebSocket != nil {
+			http.Error(w, "gRPC and WebSocket tests cannot be combined", http.StatusBadRequest)
+			return
+		}
+		if len(req.Scenario) > 0 || len(req.RequestMix) > 0 || len(req.Assertions) > 0 || req.Body != "" || req.Method != "GET" || req.GraphQL != nil || req.Cookies != nil {
+			http.Error(w, "gRPC tests send grpc.payload instead of method, body, graphql, scenario, request_mix, assertions or cookies", http.StatusBadRequest)
+			return
+		}
+		if req.Auth != nil && isSigned(req.Auth.Type) {
+			http.Error(w, "Invalid auth: gRPC tests do not support request signing", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Stream != nil {
+		if req.WebSocket != nil || req.GRPC != nil || req.GraphQL != nil {
+			http.Error(w, "Stream tests send HTTP requests and cannot be combined with websocket, grpc or graphql", http.StatusBadRequest)
+			return
+		}
+		if err := validateStreamConfig(req.Stream); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid stream: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Socket != nil {
+		if req.WebSocket != nil || req.GRPC != nil || req.GraphQL != nil || req.Stream != nil {
+			http.Error(w, "Socket tests cannot be combined with websocket, grpc, graphql or stream", http.StatusBadRequest)
+			return
+		}
+		if len(req.Scenario) > 0 || len(req.RequestMix) > 0 || len(req.Assertions) > 0 || req.Body != "" || req.Method != "GET" ||
+			len(req.Headers) > 0 || req.Auth != nil || req.Cookies != nil || req.Redirects != nil || req.TLS != nil {
+			http.Error(w, "Socket tests send socket.payload instead of method, body, headers, auth, scenario, request_mix, assertions, cookies, redirects or tls", http.StatusBadRequest)
+			return
+		}
+	}
+	baseURL := ""
+	if req.Host != "" {
+		baseURL = normalizeHost(req.Host)
+	}
+
+	// Without a scenario or request mix, every iteration is the single
+	// configured request
+	var steps []ScenarioStep
+	var mix *requestPicker
+	var socket *socketTest
+	switch {
+	case req.WebSocket != nil:
+		if err := validateWebSocketConfig(req.WebSocket); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid websocket: %v", err), http.StatusBadRequest)
+			return
+		}
+		steps = webSocketSteps(req.WebSocket, req.Host)
+		if err := compileStepTemplates(&steps[0]); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid template: %v", err), http.StatusBadRequest)
+			return
+		}
+	case req.GRPC != nil:
+		if err := validateGRPCConfig(req.GRPC, baseURL); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid grpc: %v", err), http.StatusBadRequest)
+			return
+		}
+		steps = []ScenarioStep{{URL: baseURL, Method: http.MethodPost}}
+		if err := compileStepTemplates(&steps[0]); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid template: %v", err), http.StatusBadRequest)
+			return
+		}
+	case req.Socket != nil:
+		if socket, err = validateSocketConfig(req.Socket, req.Host); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid socket: %v", err), http.StatusBadRequest)
+			return
+		}
+		steps = []ScenarioStep{{URL: baseURL, Method: req.Method}}
+		if err := compileStepTemplates(&steps[0]); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid template: %v", err), http.StatusBadRequest)
+			return
+		}
+	case len(req.Scenario) > 0:
+		if err := validateScenario(req.Scenario, baseURL); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid scenario: %v", err), http.StatusBadRequest)
+			return
+		}
+		steps = req.Scenario
+	case len(req.RequestMix) > 0:
+		if err := validateRequestMix(req.RequestMix, baseURL); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request mix: %v", err), http.StatusBadRequest)
+			return
+		}
+		steps = req.RequestMix
+		mix = newRequestPicker(steps)
+	default:
+		if err := validateAssertions(req.Assertions); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid assertions: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.GraphQL != nil {
+			if _, err := validateGraphQLRequest(req.GraphQL); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid graphql: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		steps = []ScenarioStep{{URL: normalizeHost(req.Host), Method: req.Method, Body: req.Body, Assertions: req.Assertions, GraphQL: req.GraphQL}}
+		if err := compileStepTemplates(&steps[0]); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid template: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	// Streams are read as they arrive, so nothing keeps their body
+	if req.Stream != nil {
+		for i := range steps {
+			if stepCapturesBody(&steps[i]) {
+				http.Error(w, "Invalid stream: extractors and assertions cannot read the body of a stream", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	headerTemplates, err := compileHeaderTemplates(req.Headers)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid template: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Validate test data
+	var dataSummary *DataSummary
+	var dataRows []map[string]string
+	if req.Data != nil {
+		dataSummary, dataRows, err = loadDataConfig(req.Data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid data: %v", err), http.StatusBadRequest)
+			return
+		}
+		if dataSummary.Mode == FeederUnique && req.Executor == ExecutorClosedLoop && len(dataRows) < req.Users {
+			http.Error(w, fmt.Sprintf("Unique data needs a row per user: %d rows for %d users", len(dataRows), req.Users), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Host == "" {
+		req.Host = steps[0].URL
+	}
+
+	// Validate think time and pacing; arrival-rate iterations are already paced
+	// by target_rps
+	if req.ThinkTime != nil {
+		if err := validateThinkTime(req.ThinkTime); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid think_time: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.PacingMs < 0 || req.PacingMs > MaxPacingMs {
+		http.Error(w, fmt.Sprintf("Pacing must be between 0 and %d ms", MaxPacingMs), http.StatusBadRequest)
+		return
+	}
+	if req.PacingMs > 0 && req.Executor == ExecutorArrivalRate {
+		http.Error(w, fmt.Sprintf("Pacing is not supported by the %s executor", ExecutorArrivalRate), http.StatusBadRequest)
+		return
+	}
+
+	// Validate OAuth2 settings
+	if req.Auth != nil && isOAuth2(req.Auth.Type) {
+		if err := validateOAuth2Config(req.Auth); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid auth: %v", err), http.StatusBadRequest)
+			return
+		}
+		// Every arrival-rate iteration is a new user, so a per-user cache
+		// would fetch a token per request
+		if req.Auth.TokenCache == TokenCacheUser && req.Executor == ExecutorArrivalRate {
+			http.Error(w, fmt.Sprintf("Invalid auth: token_cache %q is not supported by the %s executor", TokenCacheUser, ExecutorArrivalRate), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Validate request signing settings
+	if req.Auth != nil && isSigned(req.Auth.Type) {
+		if err := validateSigningConfig(req.Auth); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid auth: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Validate cookie and redirect settings
+	if req.Cookies != nil {
+		if err := validateCookieConfig(req.Cookies); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid cookies: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	var redirectConfig RedirectConfig
+	if req.Redirects != nil {
+		redirectConfig = *req.Redirects
+	}
+	if err := validateRedirectConfig(&redirectConfig); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid redirects: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Validate transport settings
+	var transportConfig TransportConfig
+	if req.Transport != nil {
+		transportConfig = *req.Transport
+	}
+	if err := validateTransportConfig(&transportConfig, scenarioURLs(steps), req.Users); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid transport: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.WebSocket != nil && transportConfig.HTTP2 == HTTP2H2C {
+		http.Error(w, "Invalid transport: WebSocket tests do not use h2c", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the client certificate
+	var tlsSummary *TLSSummary
+	var clientCreds *clientTLS
+	if req.TLS != nil {
+		if transportConfig.HTTP2 == HTTP2H2C {
+			http.Error(w, "Invalid tls: client certificates are not used with h2c", http.StatusBadRequest)
+			return
+		}
+		clientCreds, tlsSummary, err = parseClientTLS(req.TLS)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid tls: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Check concurrent test limit
 	tm.mu.RLock()
 	activeTestCount := len(tm.activeTests)
@@ -266,6 +673,29 @@ func (tm *TestManager) HandleStartTest(w http.ResponseWriter, r *http.Request) {
 	tm.lastTestStarts[clientIP] = now
 	tm.rateLimitMu.Unlock()
 
+	// Resolve the message types of the gRPC method, asking the server when no
+	// descriptor set was uploaded
+	var grpcMethod protoreflect.MethodDescriptor
+	if req.GRPC != nil {
+		grpcMethod, err = resolveGRPCMethod(r.Context(), req.GRPC, baseURL, transportConfig, clientCreds)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid grpc: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Everything that can fail is set up before the test run is saved, so
+	// failed starts do not show as running in the history
+	metrics := NewMetricsCollector(req.HistogramPrecision)
+	metrics.RegisterSteps(steps)
+	var call *grpcCall
+	if grpcMethod != nil {
+		if call, err = newGRPCCall(req.GRPC, grpcMethod, baseURL, transportConfig, clientCreds, metrics); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to connect to gRPC host: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Set defaults for optional fields
 	maxConcurrentRequests := req.MaxConcurrentRequests
 	if maxConcurrentRequests <= 0 {
@@ -290,7 +720,7 @@ func (tm *TestManager) HandleStartTest(w http.ResponseWriter, r *http.Request) {
 	testRun := &TestRun{
 		UUID:                  testUUID,
 		Host:                  req.Host,
-		MaskHost:		req.MaskHost,
+		MaskHost:              req.MaskHost,
 		TotalUsers:            req.Users,
 		RampUpSec:             req.RampUpSec,
 		Duration:              req.Duration,
@@ -301,10 +731,39 @@ func (tm *TestManager) HandleStartTest(w http.ResponseWriter, r *http.Request) {
 		Headers:               req.Headers,
 		MaxConcurrentRequests: maxConcurrentRequests,
 		ErrorThreshold:        errorThreshold,
+		Executor:              req.Executor,
+		TargetRPS:             req.TargetRPS,
+		Stages:                req.Stages,
+		HistogramPrecision:    req.HistogramPrecision,
+		Transport:             transportConfig,
+		Scenario:              req.Scenario,
+		RequestMix:            req.RequestMix,
+		Assertions:            req.Assertions,
+		Data:                  dataSummary,
+		ThinkTime:             req.ThinkTime,
+		PacingMs:              req.PacingMs,
+		Redirects:             redirectConfig,
+		TLS:                   tlsSummary,
+		WebSocket:             req.WebSocket,
+		GraphQL:               req.GraphQL,
+		Stream:                req.Stream,
+		Socket:                req.Socket,
+	}
+	if req.Cookies != nil {
+		testRun.Cookies = req.Cookies.redacted()
+	}
+	if req.GRPC != nil {
+		// The descriptor set is only needed to start the test
+		stored := *req.GRPC
+		stored.DescriptorSet = ""
+		testRun.GRPC = &stored
 	}
 
 	testRunID, err := SaveTestRun(tm.db, testRun)
 	if err != nil {
+		if call != nil {
+			call.close()
+		}
 		http.Error(w, fmt.Sprintf("Failed to save test run: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -313,10 +772,10 @@ func (tm *TestManager) HandleStartTest(w http.ResponseWriter, r *http.Request) {
 
 	// Create test context
 	ctx, cancel := context.WithCancel(context.Background())
-	metrics := &MetricsCollector{
-		StartTime:  time.Now(),
-		Latencies:  make([]float64, 0),
-		TimeSeries: make([]TimeSeriesPoint, 0),
+	var feeder *dataFeeder
+	if dataSummary != nil {
+		// Running out of sequential or unique rows ends the test early
+		feeder = newDataFeeder(dataSummary.Mode, dataRows, cancel)
 	}
 	isRunning := &atomic.Bool{}
 	isRunning.Store(true)
@@ -331,9 +790,19 @@ func (tm *TestManager) HandleStartTest(w http.ResponseWriter, r *http.Request) {
 		Metrics:    metrics,
 		IsRunning:  isRunning,
 		AuthConfig: req.Auth,
-		Method:     req.Method,
-		Body:       req.Body,
-		Headers:    req.Headers,
+		Steps:      steps,
+		Mix:        mix,
+		Data:       feeder,
+		Cookies:    req.Cookies,
+		Headers:    headerTemplates,
+		Writer:     NewMetricWriter(tm.db, testRunID),
+		Transport:  newTransport(transportConfig, clientCreds),
+		WebSocket:  req.WebSocket,
+		GRPC:       call,
+		Socket:     socket,
+	}
+	if req.Auth != nil && isOAuth2(req.Auth.Type) {
+		testCtx.Tokens = newTokenSource(req.Auth, testCtx)
 	}
 
 	tm.mu.Lock()
@@ -366,8 +835,14 @@ func (tm *TestManager) HandleStartTest(w http.ResponseWriter, r *http.Request) {
 
 func (tm *TestManager) runLoadTest(testCtx *TestContext, clientIP string) {
 	defer func() {
-		// Calculate final metrics before cleanup
+		// Persist the queued request metrics, then calculate final metrics
+		// before cleanup
+		testCtx.Writer.Close()
 		tm.calculateAndSaveMetrics(testCtx)
+		closeIdleConnections(testCtx.Transport)
+		if testCtx.GRPC != nil {
+			testCtx.GRPC.close()
+		}
 
 		testCtx.IsRunning.Store(false)
 		testUUID := testCtx.TestRun.UUID
@@ -405,63 +880,19 @@ func (tm *TestManager) runLoadTest(testCtx *TestContext, clientIP string) {
 	ctx := testCtx.Context
 	testRun := testCtx.TestRun
 	metrics := testCtx.Metrics
-	authConfig := testCtx.AuthConfig
 	duration := time.Duration(testRun.Duration) * time.Second
 
-	// Calculate ramp-up rate
-	usersPerSecond := float64(testRun.TotalUsers) / float64(testRun.RampUpSec)
-
 	var wg sync.WaitGroup
 	stopChan := make(chan struct{})
-	rampUpStart := time.Now()
-
-	// Start users gradually during ramp-up phase
-	go func() {
-		ticker := time.NewTicker(100 * time.Millisecond) // Check every 100ms
-		defer ticker.Stop()
-
-		usersStarted := 0
-		for usersStarted < testRun.TotalUsers {
-			select {
-			case <-ctx.Done():
-				return
-			case <-stopChan:
-				return
-			case <-ticker.C:
-				elapsed := time.Since(rampUpStart).Seconds()
-				if elapsed >= float64(testRun.RampUpSec) {
-					// Ramp-up complete, start remaining users immediately
-					for usersStarted < testRun.TotalUsers {
-						select {
-						case <-ctx.Done():
-							return
-						default:
-							wg.Add(1)
-							go tm.runUser(ctx, testRun.ID, testRun.Host, metrics, &wg, stopChan, authConfig, testRun.Method, testRun.Body, testRun.Headers, testRun.MaxConcurrentRequests)
-							usersStarted++
-						}
-					}
-					return
-				}
 
-				// Calculate target users at this point
-				targetUsers := int(elapsed * usersPerSecond)
-				if targetUsers > usersStarted {
-					usersToAdd := targetUsers - usersStarted
-					for i := 0; i < usersToAdd && usersStarted < testRun.TotalUsers; i++ {
-						select {
-						case <-ctx.Done():
-							return
-						default:
-							wg.Add(1)
-							go tm.runUser(ctx, testRun.ID, testRun.Host, metrics, &wg, stopChan, authConfig, testRun.Method, testRun.Body, testRun.Headers, testRun.MaxConcurrentRequests)
-							usersStarted++
-						}
-					}
-				}
-			}
-		}
-	}()
+	// Drive the load profile with the selected executor. It is tracked by
+	// the wait group so no user can start after the test stops.
+	wg.Add(1)
+	if testRun.Executor == ExecutorArrivalRate {
+		go tm.runArrivalRate(testCtx, &wg, stopChan)
+	} else {
+		go tm.runClosedLoop(testCtx, &wg, stopChan)
+	}
 
 	// Circuit breaker monitoring goroutine
 	circuitBreakerTicker := time.NewTicker(2 * time.Second) // Check every 2 seconds
@@ -517,21 +948,39 @@ func (tm *TestManager) runLoadTest(testCtx *TestContext, clientIP string) {
 	}
 }
 
-func (tm *TestManager) runUser(ctx context.Context, testRunID int64, host string, metrics *MetricsCollector, wg *sync.WaitGroup, stopChan <-chan struct{}, authConfig *AuthConfig, method string, body string, headers map[string]string, maxConcurrentRequests int) {
+func (tm *TestManager) runUser(testCtx *TestContext, wg *sync.WaitGroup, stopChan <-chan struct{}, retire <-chan struct{}) {
 	defer wg.Done()
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	atomic.AddInt64(&testCtx.Metrics.ActiveUsers, 1)
+	defer atomic.AddInt64(&testCtx.Metrics.ActiveUsers, -1)
+
+	if testCtx.WebSocket != nil {
+		tm.runWebSocketUser(testCtx, stopChan, retire)
+		return
 	}
 
-	// Normalize host to a valid URL
-	targetURL := normalizeHost(host)
+	ctx := testCtx.Context
+	client := newHTTPClient(testCtx)
+
+	// Calculate send interval based on max concurrent requests per second, or
+	// use the pacing as a fixed iteration duration
+	interval := time.Second / time.Duration(testCtx.TestRun.MaxConcurrentRequests)
+	paced := testCtx.TestRun.PacingMs > 0
+	if paced {
+		interval = time.Duration(testCtx.TestRun.PacingMs) * time.Millisecond
+	}
 
-	// Calculate ticker interval based on max concurrent requests per second
-	// maxConcurrentRequests requests per second = 1000ms / maxConcurrentRequests per interval
-	tickerInterval := time.Duration(1000/maxConcurrentRequests) * time.Millisecond
-	ticker := time.NewTicker(tickerInterval)
-	defer ticker.Stop()
+	// Variables extracted from responses persist across the user's iterations
+	user := newVirtualUser(testCtx, true)
+	defer user.closeSocket()
+
+	// Each iteration has an intended send time on a fixed schedule. When a
+	// slow response overruns the schedule, the missed iterations are sent as
+	// soon as the user is free instead of being skipped, and their corrected
+	// latency includes the time they spent waiting (coordinated omission).
+	intended := time.Now().Add(interval)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 
 	for {
 		select {
@@ -539,73 +988,293 @@ func (tm *TestManager) runUser(ctx context.Context, testRunID int64, host string
 			return
 		case <-stopChan:
 			return
-		case <-ticker.C:
-			start := time.Now()
-
-			// Create request with custom method, body, and context
-			var bodyReader io.Reader
-			if body != "" {
-				bodyReader = strings.NewReader(body)
+		case <-retire:
+			return
+		case <-timer.C:
+			// Think time is planned, so it moves the schedule rather than
+			// counting as falling behind it. With pacing the iteration
+			// duration already includes it.
+			thought, completed := tm.runIteration(ctx, client, testCtx, user, intended, stopChan, retire)
+			if !completed {
+				return
 			}
-
-			requestMethod := method
-			if requestMethod == "" {
-				requestMethod = "GET"
+			if paced {
+				thought = 0
 			}
+			intended = intended.Add(interval + thought)
+			timer.Reset(time.Until(intended))
+		}
+	}
+}
 
-			req, err := http.NewRequestWithContext(ctx, requestMethod, targetURL, bodyReader)
-			if err != nil {
-				metrics.Record(time.Since(start).Seconds()*1000, false, 0)
-				continue
-			}
+// executeRequest sends one step's request, records the outcome in the test's
+// collector and queues it for the request_metrics table. intendedStart is
+// when the executor meant to send it; the corrected latency is measured from
+// there.
+func (tm *TestManager) executeRequest(ctx context.Context, client *http.Client, testCtx *TestContext, step *ScenarioStep, user *virtualUser, intendedStart time.Time) {
+	if testCtx.GRPC != nil {
+		tm.invokeGRPC(ctx, testCtx, user, intendedStart)
+		return
+	}
+	if testCtx.Socket != nil {
+		tm.sendSocket(ctx, testCtx, user, intendedStart)
+		return
+	}
 
-			// Apply custom headers
-			for key, value := range headers {
-				req.Header.Set(key, value)
-			}
+	metrics := testCtx.Metrics
+	targetURL := step.url.render(user)
+	body := step.body.render(user)
+	if step.graphql != nil {
+		body = step.graphql.render(user)
+	}
+	start := time.Now()
+	if intendedStart.After(start) {
+		intendedStart = start
+	}
 
-			// Set Content-Type for POST/PUT/PATCH if body exists and not already set
-			if body != "" && (requestMethod == "POST" || requestMethod == "PUT" || requestMethod == "PATCH") {
-				if req.Header.Get("Content-Type") == "" {
-					req.Header.Set("Content-Type", "application/json")
-				}
-			}
+	// Create request with custom method, body, and context
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
 
-			// Apply authentication
-			applyAuth(req, authConfig)
+	requestMethod := step.Method
+	if requestMethod == "" {
+		requestMethod = "GET"
+	}
 
-			resp, err := client.Do(req)
-			completedAt := time.Now()
-			latency := completedAt.Sub(start).Seconds() * 1000 // Convert to milliseconds
+	req, err := http.NewRequestWithContext(ctx, requestMethod, targetURL, bodyReader)
+	if err != nil {
+		latency := time.Since(start).Seconds() * 1000
+		metrics.Record(latency, time.Since(intendedStart).Seconds()*1000, false, 0)
+		metrics.RecordError(ErrorCategoryRequestError, 0, err.Error())
+		if step.Name != "" {
+			metrics.RecordStep(step.Name, latency, false)
+		}
+		if step.graphql != nil {
+			metrics.RecordGraphQL(step.graphql.operation, latency, false, false)
+		}
+		return
+	}
 
-			success := err == nil && resp != nil && resp.StatusCode < 400
-			statusCode := 0
-			if resp != nil {
-				statusCode = resp.StatusCode
-				if _, err := io.Copy(io.Discard, resp.Body); err != nil {
-					slog.Warn("Error reading response body", "error", err, "url", targetURL)
-				}
-				if err := resp.Body.Close(); err != nil {
-					slog.Warn("Error closing response body", "error", err, "url", targetURL)
-				}
-			}
+	// Apply custom headers, then the step's own
+	for key, value := range testCtx.Headers {
+		req.Header.Set(key, value.render(user))
+	}
+	for key, value := range step.headers {
+		req.Header.Set(key, value.render(user))
+	}
 
-			metrics.Record(latency, success, statusCode)
+	// Set Content-Type for POST/PUT/PATCH if body exists and not already set
+	if body != "" && (requestMethod == "POST" || requestMethod == "PUT" || requestMethod == "PATCH") {
+		if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
 
-			metric := &RequestMetric{
-				TestRunID:  testRunID,
-				Timestamp:  completedAt,
-				Latency:    latency,
-				Success:    success,
-				StatusCode: statusCode,
+	// SSE endpoints commonly choose the response format by Accept
+	if stream := testCtx.TestRun.Stream; stream != nil && stream.Format == StreamFormatSSE && req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	// Apply authentication
+	applyAuth(req, testCtx.AuthConfig)
+	if tokens := testCtx.tokenSourceFor(user); tokens != nil {
+		token, err := tokens.Token(ctx)
+		if err != nil {
+			latency := time.Since(start).Seconds() * 1000
+			metrics.Record(latency, time.Since(intendedStart).Seconds()*1000, false, 0)
+			category := ErrorCategoryTokenError
+			if ctx.Err() != nil {
+				category = classifyError(err)
+			}
+			metrics.RecordError(category, 0, errorSample(err))
+			if step.Name != "" {
+				metrics.RecordStep(step.Name, latency, false)
 			}
-			if err := SaveRequestMetric(tm.db, metric); err != nil {
-				slog.Error("Failed to save request metric", "error", err, "test_id", testRunID)
+			if step.graphql != nil {
+				metrics.RecordGraphQL(step.graphql.operation, latency, false, false)
 			}
+			return
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		// Token requests have their own metrics, so the target's latency
+		// starts once the token is ready
+		start = time.Now()
+	}
+
+	// Separately sampled redirects are followed here, one recorded request
+	// per hop, measured from when the previous hop completed. Signed auth
+	// signs every hop as it is sent
+	for hop := 0; req != nil; hop++ {
+		if hop > 0 {
+			start = time.Now()
+			intendedStart = start
 		}
+		signRequest(req, testCtx.AuthConfig, time.Now())
+		req = tm.sendRequest(ctx, client, testCtx, step, user, req, body, start, intendedStart, hop)
 	}
 }
 
+// sendRequest sends one request of a step and records it. When the test
+// samples redirects separately and the response is a redirect with hops left,
+// it returns the request for the next hop instead of checking the response.
+func (tm *TestManager) sendRequest(ctx context.Context, client *http.Client, testCtx *TestContext, step *ScenarioStep, user *virtualUser, req *http.Request, body string, start, intendedStart time.Time, hop int) *http.Request {
+	metrics := testCtx.Metrics
+	targetURL := req.URL.String()
+
+	// The client has no timeout in a stream test; the watch bounds the wait
+	// for the response and then the stream
+	var watch *streamWatch
+	if testCtx.TestRun.Stream != nil {
+		watch = newStreamWatch(req.Context(), testCtx.TestRun.Transport.RequestTimeout())
+		defer watch.stop()
+		req = req.WithContext(watch.ctx)
+	}
+
+	trace := &phaseTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+
+	resp, err := client.Do(req)
+	completedAt := time.Now()
+	latency := completedAt.Sub(start).Seconds() * 1000 // Convert to milliseconds
+	correctedLatency := completedAt.Sub(intendedStart).Seconds() * 1000
+
+	// A status assertion replaces the default rule that any status below 400
+	// is a success
+	success := err == nil && resp != nil && (resp.StatusCode < 400 || stepAssertsStatus(step))
+	statusCode := 0
+	var phases PhaseTimings
+	var errorCategory, errorMessage string
+	var next *http.Request
+	graphQLError := false
+	if err != nil {
+		errorCategory, errorMessage = classifyError(err), errorSample(err)
+		if watch != nil && watch.timedOut() {
+			errorCategory, errorMessage = ErrorCategoryTimeout, errStreamHeaderTimeout.Error()
+		}
+	}
+	if version, cipherSuite, ok := trace.tlsSession(); ok {
+		metrics.RecordTLSSession(version, cipherSuite)
+	}
+	if resp != nil {
+		statusCode = resp.StatusCode
+		if watch != nil {
+			watch.started(testCtx.TestRun.Stream)
+		}
+		if statusCode == http.StatusUnauthorized {
+			// A rejected token is fetched again on the next request
+			if tokens := testCtx.tokenSourceFor(user); tokens != nil {
+				tokens.invalidate(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+			}
+		}
+		if testCtx.TestRun.Redirects.SeparateSamples && isRedirect(resp) {
+			if hop >= testCtx.TestRun.Redirects.maxHops() {
+				success = false
+				errorCategory, errorMessage = ErrorCategoryRedirect, errRedirectLimit.Error()
+			} else if next, err = redirectRequest(ctx, req, resp, body); err != nil {
+				success = false
+				errorCategory, errorMessage = ErrorCategoryRedirect, errorSample(err)
+			}
+		}
+		if !success && errorCategory == "" {
+			errorCategory, errorMessage = statusErrorCategory(statusCode), "HTTP "+resp.Status
+		}
+		// Keep the start of the body only when an extractor or assertion
+		// needs it; they check the final response of a redirect chain
+		final := next == nil
+		var captured []byte
+		var readErr error
+		var bodySize int64
+		var bodyDone time.Time // The download phase ends here, before the body is checked
+		if success && final && watch != nil {
+			stream := readStream(resp.Body, testCtx.TestRun.Stream, watch, start, metrics)
+			bodySize = stream.bytes
+			bodyDone = time.Now()
+			if category, message := watch.failure(ctx, stream); category != "" {
+				success = false
+				errorCategory, errorMessage = category, message
+			}
+			metrics.RecordStream(stream, success)
+		} else {
+			if success && final && stepCapturesBody(step) {
+				captured, readErr = io.ReadAll(io.LimitReader(resp.Body, MaxCaptureBytes))
+			}
+			bodySize = int64(len(captured))
+			if readErr == nil {
+				var discarded int64
+				discarded, readErr = io.Copy(io.Discard, resp.Body)
+				bodySize += discarded
+			}
+			bodyDone = time.Now()
+		}
+		if readErr != nil {
+			slog.Warn("Error reading response body", "error", readErr, "url", targetURL)
+			if success {
+				success = false
+				errorCategory, errorMessage = ErrorCategoryBodyReadError, errorSample(readErr)
+			}
+		}
+		// GraphQL errors come back with a 200, so they are checked before
+		// the assertions
+		if success && final && step.graphql != nil {
+			messages, err := graphQLErrors(captured, bodySize > int64(len(captured)))
+			switch {
+			case err != nil:
+				success = false
+				errorCategory, errorMessage = ErrorCategoryGraphQLResponse, err.Error()
+			case len(messages) > 0:
+				success, graphQLError = false, true
+				errorCategory, errorMessage = metrics.GraphQLErrorCategory(messages[0]), strings.Join(messages, "; ")
+			}
+		}
+		if success && final && len(step.Assertions) > 0 {
+			if failed, message := checkAssertions(step, resp, captured, bodySize, latency); failed != nil {
+				success = false
+				errorCategory, errorMessage = failed.Name, message
+			}
+		}
+		if success && final && len(step.Extract) > 0 {
+			if err := runExtractors(step, resp, captured, user); err != nil {
+				success = false
+				errorCategory, errorMessage = ErrorCategoryExtractionFailed, err.Error()
+			}
+		}
+		phases = trace.timings(bodyDone)
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("Error closing response body", "error", err, "url", targetURL)
+		}
+		metrics.RecordPhases(phases)
+	}
+
+	metrics.Record(latency, correctedLatency, success, statusCode)
+	if !success {
+		metrics.RecordError(errorCategory, statusCode, errorMessage)
+	}
+	if step.Name != "" {
+		metrics.RecordStep(step.Name, latency, success)
+	}
+	if step.graphql != nil {
+		metrics.RecordGraphQL(step.graphql.operation, latency, success, graphQLError)
+	}
+
+	metric := &RequestMetric{
+		TestRunID:        testCtx.TestRun.ID,
+		Timestamp:        completedAt,
+		Latency:          latency,
+		CorrectedLatency: correctedLatency,
+		Success:          success,
+		StatusCode:       statusCode,
+		ErrorCategory:    errorCategory,
+		Phases:           phases,
+	}
+	testCtx.Writer.Write(metric)
+	if !success {
+		return nil
+	}
+	return next
+}
+
 // applyAuth applies authentication to the HTTP request based on auth config
 func applyAuth(req *http.Request, authConfig *AuthConfig) {
 	if authConfig == nil {
@@ -701,8 +1370,9 @@ func validateHost(host string) error {
 func normalizeHost(host string) string {
 	host = strings.TrimSpace(host)
 
-	// If it already starts with http:// or https://, return as is
-	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+	// If it already starts with http:// or https://, or is a WebSocket URL,
+	// return as is
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") || isWebSocketURL(host) {
 		return host
 	}
 
@@ -765,31 +1435,12 @@ func (tm *TestManager) calculateAndSaveMetrics(testCtx *TestContext) {
 	metrics := testCtx.Metrics
 	testRun := testCtx.TestRun
 
-	metrics.mu.RLock()
-	totalRequests := metrics.TotalRequests
-	successCount := metrics.SuccessCount
-	errorCount := metrics.ErrorCount
-	latencies := make([]float64, len(metrics.Latencies))
-	copy(latencies, metrics.Latencies)
+	totalRequests := atomic.LoadInt64(&metrics.TotalRequests)
+	successCount := atomic.LoadInt64(&metrics.SuccessCount)
+	errorCount := atomic.LoadInt64(&metrics.ErrorCount)
+	droppedIterations := atomic.LoadInt64(&metrics.DroppedIterations)
 	duration := time.Since(metrics.StartTime).Seconds()
-	metrics.mu.RUnlock()
-
-	var avgLatency, minLatency, maxLatency float64
-	if len(latencies) > 0 {
-		var sum float64
-		minLatency = latencies[0]
-		maxLatency = latencies[0]
-		for _, lat := range latencies {
-			sum += lat
-			if lat < minLatency {
-				minLatency = lat
-			}
-			if lat > maxLatency {
-				maxLatency = lat
-			}
-		}
-		avgLatency = sum / float64(len(latencies))
-	}
+	service, corrected := metrics.LatencySnapshot()
 
 	rps := float64(totalRequests) / duration
 
@@ -799,14 +1450,97 @@ func (tm *TestManager) calculateAndSaveMetrics(testCtx *TestContext) {
 	testRun.TotalRequests = totalRequests
 	testRun.SuccessCount = successCount
 	testRun.ErrorCount = errorCount
-	testRun.AvgLatency = avgLatency
-	testRun.MinLatency = minLatency
-	testRun.MaxLatency = maxLatency
+	testRun.AvgLatency = service.Avg
+	testRun.MinLatency = service.Min
+	testRun.MaxLatency = service.Max
+	testRun.CorrectedAvgLatency = corrected.Avg
+	testRun.CorrectedMaxLatency = corrected.Max
 	testRun.RPS = rps
+	testRun.DroppedIterations = droppedIterations
+	testRun.SessionsEstablished = atomic.LoadInt64(&metrics.SessionsEstablished)
+	testRun.TokenMetrics = metrics.TokenMetrics()
+	testRun.TLSSessions = metrics.TLSSessions()
+	testRun.WebSocketMetrics = metrics.WebSocketMetrics()
+	testRun.GRPCMetrics = metrics.GRPCMetrics()
+	testRun.GraphQLOperations = metrics.GraphQLOperations()
+	testRun.StreamMetrics = metrics.StreamMetrics()
+	testRun.SocketMetrics = metrics.SocketMetrics()
+	testRun.DroppedSamples = testCtx.Writer.Dropped()
+	testRun.PhaseBreakdown = metrics.PhaseBreakdown()
+	errorBreakdown := metrics.ErrorBreakdown()
+	testRun.ErrorBreakdown = &errorBreakdown
+	testRun.StatusCodes = metrics.StatusCodes()
+	testRun.StepMetrics = metrics.StepMetrics()
 
 	if err := UpdateTestRun(tm.db, testCtx.TestRun); err != nil {
 		slog.Error("Failed to update test run", "error", err, "test_id", testCtx.TestRun.ID)
 	}
+
+	serviceHist, correctedHist, err := metrics.EncodeHistograms()
+	if err != nil {
+		slog.Error("Failed to encode latency histograms", "error", err, "test_id", testCtx.TestRun.ID)
+		return
+	}
+	if err := SaveLatencyHistograms(tm.db, testRun.ID, serviceHist, correctedHist); err != nil {
+		slog.Error("Failed to save latency histograms", "error", err, "test_id", testCtx.TestRun.ID)
+	}
+}
+
+// storedLatencyStats summarises the latency histograms persisted with a
+// finished test. ok is false for tests recorded before histograms were stored.
+func (tm *TestManager) storedLatencyStats(testRunID int64) (service, corrected LatencyStats, ok bool) {
+	serviceHist, correctedHist, err := GetLatencyHistograms(tm.db, testRunID)
+	if err != nil || serviceHist == "" || correctedHist == "" {
+		return LatencyStats{}, LatencyStats{}, false
+	}
+
+	serviceH, err := decodeHistogram(serviceHist)
+	if err != nil {
+		slog.Error("Failed to decode latency histogram", "error", err, "test_id", testRunID)
+		return LatencyStats{}, LatencyStats{}, false
+	}
+	correctedH, err := decodeHistogram(correctedHist)
+	if err != nil {
+		slog.Error("Failed to decode latency histogram", "error", err, "test_id", testRunID)
+		return LatencyStats{}, LatencyStats{}, false
+	}
+	return histogramStats(serviceH), histogramStats(correctedH), true
+}
+
+// setPercentileFields adds the service and corrected latency percentiles to a
+// metrics response.
+func setPercentileFields(response map[string]interface{}, service, corrected LatencyStats) {
+	response["p50_latency"] = service.P50
+	response["p90_latency"] = service.P90
+	response["p95_latency"] = service.P95
+	response["p99_latency"] = service.P99
+	response["p999_latency"] = service.P999
+	response["corrected_p50_latency"] = corrected.P50
+	response["corrected_p90_latency"] = corrected.P90
+	response["corrected_p95_latency"] = corrected.P95
+	response["corrected_p99_latency"] = corrected.P99
+	response["corrected_p999_latency"] = corrected.P999
+}
+
+// storedStatusCodes returns a finished test's requests per status code,
+// counting the stored request metrics for tests recorded before the
+// distribution was persisted.
+func (tm *TestManager) storedStatusCodes(testRun *TestRun) map[int]int64 {
+	if testRun.StatusCodes != nil {
+		return testRun.StatusCodes
+	}
+	metrics, err := GetRequestMetrics(tm.db, testRun.ID)
+	if err != nil {
+		slog.Error("Failed to get request metrics", "error", err, "test_id", testRun.ID)
+		return map[int]int64{}
+	}
+	return statusCodesFromMetrics(metrics)
+}
+
+// setStatusCodeFields adds the status code distribution to a metrics response.
+func setStatusCodeFields(response map[string]interface{}, statusCodes map[int]int64) {
+	response["status_codes"] = statusCodes
+	response["status_classes"] = statusClasses(statusCodes)
 }
 
 func (tm *TestManager) HandleGetStatus(w http.ResponseWriter, r *http.Request) {
@@ -819,8 +1553,9 @@ func (tm *TestManager) HandleGetStatus(w http.ResponseWriter, r *http.Request) {
 	if exists {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"is_running": testCtx.IsRunning.Load(),
-			"test_run":   testCtx.TestRun,
+			"is_running":   testCtx.IsRunning.Load(),
+			"test_run":     testCtx.TestRun,
+			"load_profile": testCtx.TestRun.LoadProfile(),
 		})
 		return
 	}
@@ -834,8 +1569,9 @@ func (tm *TestManager) HandleGetStatus(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"is_running": false,
-		"test_run":   testRun,
+		"is_running":   false,
+		"test_run":     testRun,
+		"load_profile": testRun.LoadProfile(),
 	})
 }
 
@@ -878,65 +1614,56 @@ func (tm *TestManager) HandleGetMetrics(w http.ResponseWriter, r *http.Request)
 			errorRate = (float64(testRun.ErrorCount) / float64(testRun.TotalRequests)) * 100
 		}
 
+		response := map[string]interface{}{
+			"total_requests":        testRun.TotalRequests,
+			"success_count":         testRun.SuccessCount,
+			"error_count":           testRun.ErrorCount,
+			"avg_latency":           testRun.AvgLatency,
+			"min_latency":           testRun.MinLatency,
+			"max_latency":           testRun.MaxLatency,
+			"error_rate":            errorRate,
+			"corrected_avg_latency": testRun.CorrectedAvgLatency,
+			"corrected_max_latency": testRun.CorrectedMaxLatency,
+			"avg_rps":               testRun.RPS,
+			"rps":                   testRun.RPS,
+			"duration":              float64(testRun.Duration),
+			"is_running":            false,
+			"executor":              testRun.Executor,
+			"target_rps":            testRun.TargetRPS,
+			"dropped_iterations":    testRun.DroppedIterations,
+			"dropped_samples":       testRun.DroppedSamples,
+			"sessions_established":  testRun.SessionsEstablished,
+			"token_metrics":         testRun.TokenMetrics,
+			"tls":                   testRun.TLS,
+			"tls_sessions":          testRun.TLSSessions,
+			"websocket":             testRun.WebSocket,
+			"websocket_metrics":     testRun.WebSocketMetrics,
+			"grpc":                  testRun.GRPC,
+			"grpc_metrics":          testRun.GRPCMetrics,
+			"graphql":               testRun.GraphQL,
+			"graphql_operations":    testRun.GraphQLOperations,
+			"stream":                testRun.Stream,
+			"stream_metrics":        testRun.StreamMetrics,
+			"socket":                testRun.Socket,
+			"socket_metrics":        testRun.SocketMetrics,
+			"steps":                 testRun.StepMetrics,
+			"histogram_precision":   testRun.HistogramPrecision,
+			"phase_breakdown":       testRun.PhaseBreakdown,
+		}
+		// Percentiles come from the persisted histograms; tests recorded
+		// before they were stored report 0
+		service, corrected, _ := tm.storedLatencyStats(testRun.ID)
+		setPercentileFields(response, service, corrected)
+		setStatusCodeFields(response, tm.storedStatusCodes(testRun))
+
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"total_requests": testRun.TotalRequests,
-			"success_count":  testRun.SuccessCount,
-			"error_count":    testRun.ErrorCount,
-			"avg_latency":    testRun.AvgLatency,
-			"min_latency":    testRun.MinLatency,
-			"max_latency":    testRun.MaxLatency,
-			"p50_latency":    0.0, // Not stored for completed tests
-			"p95_latency":    0.0, // Not stored for completed tests
-			"p99_latency":    0.0, // Not stored for completed tests
-			"error_rate":     errorRate,
-			"avg_rps":        testRun.RPS,
-			"rps":            testRun.RPS,
-			"duration":       float64(testRun.Duration),
-			"is_running":     false,
-		})
+		json.NewEncoder(w).Encode(response)
 		return
 	}
 
 	metrics := testCtx.Metrics
-	metrics.mu.RLock()
-	latencies := make([]float64, len(metrics.Latencies))
-	copy(latencies, metrics.Latencies)
 	duration := time.Since(metrics.StartTime).Seconds()
-	metrics.mu.RUnlock()
-
-	var avgLatency, minLatency, maxLatency, p50Latency, p95Latency, p99Latency float64
-	if len(latencies) > 0 {
-		// Sort latencies for percentile calculation
-		sortedLatencies := make([]float64, len(latencies))
-		copy(sortedLatencies, latencies)
-		sort.Float64s(sortedLatencies)
-
-		var sum float64
-		minLatency = sortedLatencies[0]
-		maxLatency = sortedLatencies[len(sortedLatencies)-1]
-		for _, lat := range sortedLatencies {
-			sum += lat
-		}
-		avgLatency = sum / float64(len(sortedLatencies))
-
-		// Calculate percentiles
-		if len(sortedLatencies) > 0 {
-			p50Index := int(float64(len(sortedLatencies)) * 0.50)
-			p95Index := int(float64(len(sortedLatencies)) * 0.95)
-			p99Index := int(float64(len(sortedLatencies)) * 0.99)
-
-			if p50Index < len(sortedLatencies) {
-				p50Latency = sortedLatencies[p50Index]
-			}
-			if p95Index < len(sortedLatencies) {
-				p95Latency = sortedLatencies[p95Index]
-			}
-			if p99Index < len(sortedLatencies) {
-				p99Latency = sortedLatencies[p99Index]
-			}
-		}
-	}
+	service, corrected := metrics.LatencySnapshot()
 
 	totalRequests := atomic.LoadInt64(&metrics.TotalRequests)
 	successCount := atomic.LoadInt64(&metrics.SuccessCount)
@@ -959,24 +1686,50 @@ func (tm *TestManager) HandleGetMetrics(w http.ResponseWriter, r *http.Request)
 	}
 	metrics.mu.RUnlock()
 
+	response := map[string]interface{}{
+		"total_requests":        totalRequests,
+		"success_count":         successCount,
+		"error_count":           errorCount,
+		"avg_latency":           service.Avg,
+		"min_latency":           service.Min,
+		"max_latency":           service.Max,
+		"error_rate":            errorRate,
+		"corrected_avg_latency": corrected.Avg,
+		"corrected_max_latency": corrected.Max,
+		"avg_rps":               avgRPS,
+		"rps":                   rps,
+		"duration":              duration,
+		"is_running":            testCtx.IsRunning.Load(),
+		"stopped_by_circuit":    testCtx.TestRun.StoppedByCircuit,
+		"executor":              testCtx.TestRun.Executor,
+		"target_rps":            testCtx.TestRun.TargetRPS,
+		"dropped_iterations":    atomic.LoadInt64(&metrics.DroppedIterations),
+		"dropped_samples":       testCtx.Writer.Dropped(),
+		"sessions_established":  atomic.LoadInt64(&metrics.SessionsEstablished),
+		"token_metrics":         metrics.TokenMetrics(),
+		"tls":                   testCtx.TestRun.TLS,
+		"tls_sessions":          metrics.TLSSessions(),
+		"websocket":             testCtx.TestRun.WebSocket,
+		"websocket_metrics":     metrics.WebSocketMetrics(),
+		"grpc":                  testCtx.TestRun.GRPC,
+		"grpc_metrics":          metrics.GRPCMetrics(),
+		"graphql":               testCtx.TestRun.GraphQL,
+		"graphql_operations":    metrics.GraphQLOperations(),
+		"stream":                testCtx.TestRun.Stream,
+		"stream_metrics":        metrics.StreamMetrics(),
+		"socket":                testCtx.TestRun.Socket,
+		"socket_metrics":        metrics.SocketMetrics(),
+		"steps":                 metrics.StepMetrics(),
+		"active_users":          atomic.LoadInt64(&metrics.ActiveUsers),
+		"target_load":           metrics.TargetLoad(),
+		"histogram_precision":   testCtx.TestRun.HistogramPrecision,
+		"phase_breakdown":       metrics.PhaseBreakdown(),
+	}
+	setPercentileFields(response, service, corrected)
+	setStatusCodeFields(response, metrics.StatusCodes())
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"total_requests":     totalRequests,
-		"success_count":      successCount,
-		"error_count":        errorCount,
-		"avg_latency":        avgLatency,
-		"min_latency":        minLatency,
-		"max_latency":        maxLatency,
-		"p50_latency":        p50Latency,
-		"p95_latency":        p95Latency,
-		"p99_latency":        p99Latency,
-		"error_rate":         errorRate,
-		"avg_rps":            avgRPS,
-		"rps":                rps,
-		"duration":           duration,
-		"is_running":         testCtx.IsRunning.Load(),
-		"stopped_by_circuit": testCtx.TestRun.StoppedByCircuit,
-	})
+	json.NewEncoder(w).Encode(response)
 }
 
 func (tm *TestManager) HandleGetHistory(w http.ResponseWriter, r *http.Request) {
@@ -1031,28 +1784,11 @@ func (tm *TestManager) HandleGetHistoricalMetrics(w http.ResponseWriter, r *http
 		return
 	}
 
-	// Calculate percentiles if we have data
-	var p50Latency, p95Latency, p99Latency float64
-	if len(metrics) > 0 {
-		latencies := make([]float64, len(metrics))
-		for i, m := range metrics {
-			latencies[i] = m.Latency
-		}
-		sort.Float64s(latencies)
-
-		p50Index := int(float64(len(latencies)) * 0.50)
-		p95Index := int(float64(len(latencies)) * 0.95)
-		p99Index := int(float64(len(latencies)) * 0.99)
-
-		if p50Index < len(latencies) {
-			p50Latency = latencies[p50Index]
-		}
-		if p95Index < len(latencies) {
-			p95Latency = latencies[p95Index]
-		}
-		if p99Index < len(latencies) {
-			p99Latency = latencies[p99Index]
-		}
+	// Use the persisted histograms, falling back to the stored request
+	// metrics for tests recorded before histograms were kept
+	service, corrected, ok := tm.storedLatencyStats(testRun.ID)
+	if !ok {
+		service, corrected = historicalLatencyStats(metrics)
 	}
 
 	errorRate := float64(0)
@@ -1061,46 +1797,101 @@ func (tm *TestManager) HandleGetHistoricalMetrics(w http.ResponseWriter, r *http
 	}
 
 	// Build time series data
-	timeSeries := buildTimeSeriesFromMetrics(metrics, testRun.StartedAt)
+	timeSeries := buildTimeSeriesFromMetrics(metrics, testRun.StartedAt, testRun.LoadProfile())
+
+	response := map[string]interface{}{
+		"id":                    testRun.ID,
+		"host":                  testRun.Host,
+		"total_requests":        testRun.TotalRequests,
+		"success_count":         testRun.SuccessCount,
+		"error_count":           testRun.ErrorCount,
+		"avg_latency":           testRun.AvgLatency,
+		"min_latency":           testRun.MinLatency,
+		"max_latency":           testRun.MaxLatency,
+		"error_rate":            errorRate,
+		"corrected_avg_latency": corrected.Avg,
+		"corrected_max_latency": corrected.Max,
+		"rps":                   testRun.RPS,
+		"duration":              testRun.Duration,
+		"started_at":            testRun.StartedAt,
+		"completed_at":          testRun.CompletedAt,
+		"time_series":           timeSeries,
+		"executor":              testRun.Executor,
+		"target_rps":            testRun.TargetRPS,
+		"dropped_iterations":    testRun.DroppedIterations,
+		"dropped_samples":       testRun.DroppedSamples,
+		"scenario":              testRun.Scenario,
+		"request_mix":           testRun.RequestMix,
+		"assertions":            testRun.Assertions,
+		"data":                  testRun.Data,
+		"think_time":            testRun.ThinkTime,
+		"pacing_ms":             testRun.PacingMs,
+		"cookies":               testRun.Cookies,
+		"redirects":             testRun.Redirects,
+		"sessions_established":  testRun.SessionsEstablished,
+		"token_metrics":         testRun.TokenMetrics,
+		"tls":                   testRun.TLS,
+		"tls_sessions":          testRun.TLSSessions,
+		"websocket":             testRun.WebSocket,
+		"websocket_metrics":     testRun.WebSocketMetrics,
+		"grpc":                  testRun.GRPC,
+		"grpc_metrics":          testRun.GRPCMetrics,
+		"graphql":               testRun.GraphQL,
+		"graphql_operations":    testRun.GraphQLOperations,
+		"stream":                testRun.Stream,
+		"stream_metrics":        testRun.StreamMetrics,
+		"socket":                testRun.Socket,
+		"socket_metrics":        testRun.SocketMetrics,
+		"steps":                 testRun.StepMetrics,
+		"load_profile":          testRun.LoadProfile(),
+		"histogram_precision":   testRun.HistogramPrecision,
+		"phase_breakdown":       testRun.PhaseBreakdown,
+	}
+	setPercentileFields(response, service, corrected)
+	statusCodes := testRun.StatusCodes
+	if statusCodes == nil {
+		statusCodes = statusCodesFromMetrics(metrics)
+	}
+	setStatusCodeFields(response, statusCodes)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":             testRun.ID,
-		"host":           testRun.Host,
-		"total_requests": testRun.TotalRequests,
-		"success_count":  testRun.SuccessCount,
-		"error_count":    testRun.ErrorCount,
-		"avg_latency":    testRun.AvgLatency,
-		"min_latency":    testRun.MinLatency,
-		"max_latency":    testRun.MaxLatency,
-		"p50_latency":    p50Latency,
-		"p95_latency":    p95Latency,
-		"p99_latency":    p99Latency,
-		"error_rate":     errorRate,
-		"rps":            testRun.RPS,
-		"duration":       testRun.Duration,
-		"started_at":     testRun.StartedAt,
-		"completed_at":   testRun.CompletedAt,
-		"time_series":    timeSeries,
-	})
+	json.NewEncoder(w).Encode(response)
 }
 
-func buildTimeSeriesFromMetrics(metrics []*RequestMetric, startTime time.Time) []map[string]interface{} {
-	points := buildTimeSeriesPoints(metrics, startTime)
+func buildTimeSeriesFromMetrics(metrics []*RequestMetric, startTime time.Time, profile []Stage) []map[string]interface{} {
+	points := buildTimeSeriesPoints(metrics, startTime, profile)
 	timeSeries := make([]map[string]interface{}, 0, len(points))
 	for _, point := range points {
 		timeSeries = append(timeSeries, map[string]interface{}{
-			"timestamp":    point.Timestamp,
-			"requests":     point.Requests,
-			"rps":          point.RPS,
-			"avg_latency":  point.AvgLatency,
-			"success_rate": point.SuccessRate,
+			"timestamp":      point.Timestamp,
+			"requests":       point.Requests,
+			"rps":            point.RPS,
+			"avg_latency":    point.AvgLatency,
+			"success_rate":   point.SuccessRate,
+			"target_load":    point.TargetLoad,
+			"phases":         point.Phases,
+			"status_classes": point.StatusClasses,
 		})
 	}
 	return timeSeries
 }
 
-func buildTimeSeriesPoints(metrics []*RequestMetric, startTime time.Time) []TimeSeriesPoint {
+// historicalLatencyStats summarises stored request metrics into service and
+// corrected latency distributions.
+func historicalLatencyStats(metrics []*RequestMetric) (service, corrected LatencyStats) {
+	latencies := make([]float64, len(metrics))
+	correctedLatencies := make([]float64, len(metrics))
+	for i, m := range metrics {
+		latencies[i] = m.Latency
+		correctedLatencies[i] = m.CorrectedLatency
+	}
+	return computeLatencyStats(latencies), computeLatencyStats(correctedLatencies)
+}
+
+// buildTimeSeriesPoints rebuilds per-second points from stored request
+// metrics. The profile supplies each point's target load, since active user
+// counts are only sampled while the test is running.
+func buildTimeSeriesPoints(metrics []*RequestMetric, startTime time.Time, profile []Stage) []TimeSeriesPoint {
 	if len(metrics) == 0 {
 		return []TimeSeriesPoint{}
 	}
@@ -1109,6 +1900,9 @@ func buildTimeSeriesPoints(metrics []*RequestMetric, startTime time.Time) []Time
 		latencies    []float64
 		successCount int
 		totalCount   int
+		phaseSum     PhaseTimings
+		phaseCount   int64
+		statuses     StatusClassCounts
 	}
 
 	buckets := make(map[int]*bucket)
@@ -1133,6 +1927,11 @@ func buildTimeSeriesPoints(metrics []*RequestMetric, startTime time.Time) []Time
 
 		b.latencies = append(b.latencies, m.Latency)
 		b.totalCount++
+		b.statuses.add(m.StatusCode, 1)
+		if m.StatusCode != 0 {
+			b.phaseSum = b.phaseSum.add(m.Phases)
+			b.phaseCount++
+		}
 		if m.Success {
 			b.successCount++
 		}
@@ -1161,17 +1960,62 @@ func buildTimeSeriesPoints(metrics []*RequestMetric, startTime time.Time) []Time
 		}
 
 		points = append(points, TimeSeriesPoint{
-			Timestamp:   startTime.Add(time.Duration(second) * time.Second),
-			Requests:    int64(bucket.totalCount),
-			RPS:         float64(bucket.totalCount),
-			AvgLatency:  avgLatency,
-			SuccessRate: successRate,
+			Timestamp:     startTime.Add(time.Duration(second) * time.Second),
+			Requests:      int64(bucket.totalCount),
+			RPS:           float64(bucket.totalCount),
+			AvgLatency:    avgLatency,
+			SuccessRate:   successRate,
+			TargetLoad:    profileTarget(profile, time.Duration(second)*time.Second),
+			Phases:        bucket.phaseSum.average(bucket.phaseCount),
+			StatusClasses: bucket.statuses,
 		})
 	}
 
 	return points
 }
 
+// HandleGetErrors returns a test's failed requests broken down by error
+// category and status code, with a sample message for each category.
+func (tm *TestManager) HandleGetErrors(w http.ResponseWriter, r *http.Request) {
+	testUUID := r.URL.Path[len("/api/errors/"):]
+
+	tm.mu.RLock()
+	testCtx, exists := tm.activeTests[testUUID]
+	tm.mu.RUnlock()
+
+	var breakdown ErrorBreakdown
+	var errorCount int64
+	if exists {
+		breakdown = testCtx.Metrics.ErrorBreakdown()
+		errorCount = atomic.LoadInt64(&testCtx.Metrics.ErrorCount)
+	} else {
+		testRun, err := GetTestRunByUUID(tm.db, testUUID)
+		if err != nil {
+			http.Error(w, "Test not found", http.StatusNotFound)
+			return
+		}
+		errorCount = testRun.ErrorCount
+		if testRun.ErrorBreakdown != nil {
+			breakdown = *testRun.ErrorBreakdown
+		} else {
+			// Tests recorded before breakdowns were stored
+			metrics, err := GetRequestMetrics(tm.db, testRun.ID)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to get metrics: %v", err), http.StatusInternalServerError)
+				return
+			}
+			breakdown = errorBreakdownFromMetrics(metrics)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error_count":  errorCount,
+		"categories":   breakdown.Categories,
+		"status_codes": breakdown.StatusCodes,
+	})
+}
+
 func (tm *TestManager) HandleGetTimeSeries(w http.ResponseWriter, r *http.Request) {
 	testUUID := r.URL.Path[len("/api/timeseries/"):]
 
@@ -1238,8 +2082,10 @@ func (tm *TestManager) HandleGenerateReport(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Get time series if test is active
+	// Get time series and latency distributions from the live collector if
+	// the test is active, otherwise from stored request metrics
 	var timeSeries []TimeSeriesPoint
+	var reportData ReportData
 	tm.mu.RLock()
 	testCtx, exists := tm.activeTests[testUUID]
 	tm.mu.RUnlock()
@@ -1249,17 +2095,52 @@ func (tm *TestManager) HandleGenerateReport(w http.ResponseWriter, r *http.Reque
 		timeSeries = make([]TimeSeriesPoint, len(testCtx.Metrics.TimeSeries))
 		copy(timeSeries, testCtx.Metrics.TimeSeries)
 		testCtx.Metrics.mu.RUnlock()
+		reportData.ServiceLatency, reportData.CorrectedLatency = testCtx.Metrics.LatencySnapshot()
+		reportData.Phases = testCtx.Metrics.PhaseBreakdown()
+		reportData.Errors = testCtx.Metrics.ErrorBreakdown()
+		reportData.StatusCodes = testCtx.Metrics.StatusCodes()
+		reportData.Steps = testCtx.Metrics.StepMetrics()
+		reportData.Tokens = testCtx.Metrics.TokenMetrics()
+		reportData.TLSSessions = testCtx.Metrics.TLSSessions()
+		reportData.WebSocket = testCtx.Metrics.WebSocketMetrics()
+		reportData.GRPC = testCtx.Metrics.GRPCMetrics()
+		reportData.GraphQL = testCtx.Metrics.GraphQLOperations()
+		reportData.Stream = testCtx.Metrics.StreamMetrics()
+		reportData.Socket = testCtx.Metrics.SocketMetrics()
 	} else {
+		reportData.Phases = testRun.PhaseBreakdown
+		reportData.Steps = testRun.StepMetrics
+		reportData.Tokens = testRun.TokenMetrics
+		reportData.TLSSessions = testRun.TLSSessions
+		reportData.WebSocket = testRun.WebSocketMetrics
+		reportData.GRPC = testRun.GRPCMetrics
+		reportData.GraphQL = testRun.GraphQLOperations
+		reportData.Stream = testRun.StreamMetrics
+		reportData.Socket = testRun.SocketMetrics
 		historicalMetrics, err := GetRequestMetrics(tm.db, testRun.ID)
 		if err == nil {
-			timeSeries = buildTimeSeriesPoints(historicalMetrics, testRun.StartedAt)
+			timeSeries = buildTimeSeriesPoints(historicalMetrics, testRun.StartedAt, testRun.LoadProfile())
+			var ok bool
+			reportData.ServiceLatency, reportData.CorrectedLatency, ok = tm.storedLatencyStats(testRun.ID)
+			if !ok {
+				reportData.ServiceLatency, reportData.CorrectedLatency = historicalLatencyStats(historicalMetrics)
+			}
+			if testRun.ErrorBreakdown != nil {
+				reportData.Errors = *testRun.ErrorBreakdown
+			} else {
+				reportData.Errors = errorBreakdownFromMetrics(historicalMetrics)
+			}
+			reportData.StatusCodes = testRun.StatusCodes
+			if reportData.StatusCodes == nil {
+				reportData.StatusCodes = statusCodesFromMetrics(historicalMetrics)
+			}
 		} else {
 			log.Printf("failed to load historical time series for test %s: %v", testUUID, err)
 		}
 	}
 
 	// Generate PDF
-	pdfBytes, err := GeneratePDFReport(testRun, timeSeries)
+	pdfBytes, err := GeneratePDFReport(testRun, timeSeries, reportData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to generate PDF: %v", err), http.StatusInternalServerError)
 		return
@@ -1270,7 +2151,7 @@ func (tm *TestManager) HandleGenerateReport(w http.ResponseWriter, r *http.Reque
 	w.Write(pdfBytes)
 }
 
-func (mc *MetricsCollector) Record(latency float64, success bool, statusCode int) {
+func (mc *MetricsCollector) Record(latency, correctedLatency float64, success bool, statusCode int) {
 	atomic.AddInt64(&mc.TotalRequests, 1)
 	if success {
 		atomic.AddInt64(&mc.SuccessCount, 1)
@@ -1279,20 +2160,121 @@ func (mc *MetricsCollector) Record(latency float64, success bool, statusCode int
 	}
 
 	mc.mu.Lock()
-	mc.Latencies = append(mc.Latencies, latency)
-	// Keep only last MaxLatencySamples latencies to avoid memory issues
-	if len(mc.Latencies) > MaxLatencySamples {
-		mc.Latencies = mc.Latencies[len(mc.Latencies)-MaxLatencySamples:]
+	recordLatency(mc.Latencies, latency)
+	recordLatency(mc.CorrectedLatencies, correctedLatency)
+	mc.intervalLatency += latency
+	mc.intervalCount++
+	mc.statusCodes[statusCode]++
+	mc.intervalStatus.add(statusCode, 1)
+	mc.mu.Unlock()
+}
+
+// RecordWithoutStatus records a WebSocket message round trip or a gRPC call
+// like Record, without an HTTP status code.
+func (mc *MetricsCollector) RecordWithoutStatus(latency, correctedLatency float64, success bool) {
+	atomic.AddInt64(&mc.TotalRequests, 1)
+	if success {
+		atomic.AddInt64(&mc.SuccessCount, 1)
+	} else {
+		atomic.AddInt64(&mc.ErrorCount, 1)
 	}
+
+	mc.mu.Lock()
+	recordLatency(mc.Latencies, latency)
+	recordLatency(mc.CorrectedLatencies, correctedLatency)
+	mc.intervalLatency += latency
+	mc.intervalCount++
+	mc.mu.Unlock()
+}
+
+// LatencySnapshot summarises the service and corrected latencies of every
+// request recorded so far.
+func (mc *MetricsCollector) LatencySnapshot() (service, corrected LatencyStats) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return histogramStats(mc.Latencies), histogramStats(mc.CorrectedLatencies)
+}
+
+// RecordPhases adds one request's timing phases to the test's breakdown.
+func (mc *MetricsCollector) RecordPhases(phases PhaseTimings) {
+	mc.mu.Lock()
+	mc.phaseSum = mc.phaseSum.add(phases)
+	mc.phaseCount++
+	mc.intervalPhaseSum = mc.intervalPhaseSum.add(phases)
+	mc.intervalPhaseCount++
+	mc.mu.Unlock()
+}
+
+// RecordError adds a failed request to the test's error breakdown.
+func (mc *MetricsCollector) RecordError(category string, statusCode int, message string) {
+	mc.mu.Lock()
+	mc.errors.add(category, statusCode, message)
 	mc.mu.Unlock()
 }
 
+// ErrorBreakdown returns a copy of the test's error breakdown.
+func (mc *MetricsCollector) ErrorBreakdown() ErrorBreakdown {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.errors.clone()
+}
+
+// StatusCodes returns a copy of the number of requests per status code.
+func (mc *MetricsCollector) StatusCodes() map[int]int64 {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	codes := make(map[int]int64, len(mc.statusCodes))
+	for code, count := range mc.statusCodes {
+		codes[code] = count
+	}
+	return codes
+}
+
+// PhaseBreakdown returns the average timing phases of the requests so far.
+func (mc *MetricsCollector) PhaseBreakdown() PhaseTimings {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.phaseSum.average(mc.phaseCount)
+}
+
+// EncodeHistograms serializes the service and corrected latency histograms.
+func (mc *MetricsCollector) EncodeHistograms() (service, corrected string, err error) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	if service, err = encodeHistogram(mc.Latencies); err != nil {
+		return "", "", err
+	}
+	if corrected, err = encodeHistogram(mc.CorrectedLatencies); err != nil {
+		return "", "", err
+	}
+	return service, corrected, nil
+}
+
+// SetTargetLoad publishes the load the profile currently asks for, in users or
+// requests per second depending on the executor.
+func (mc *MetricsCollector) SetTargetLoad(target float64) {
+	mc.targetLoad.Store(math.Float64bits(target))
+}
+
+// TargetLoad returns the value last passed to SetTargetLoad.
+func (mc *MetricsCollector) TargetLoad() float64 {
+	return math.Float64frombits(mc.targetLoad.Load())
+}
+
+// RecordDropped counts an arrival that could not be started because every
+// worker in the pool was still busy.
+func (mc *MetricsCollector) RecordDropped() {
+	atomic.AddInt64(&mc.DroppedIterations, 1)
+}
+
 func (mc *MetricsCollector) collectTimeSeries(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
 	lastRequestCount := int64(0)
 	lastTimestamp := time.Now()
+	var avgLatency float64
+	var phases PhaseTimings
 
 	for {
 		select {
@@ -1308,22 +2290,20 @@ func (mc *MetricsCollector) collectTimeSeries(ctx context.Context) {
 			if elapsed > 0 {
 				rps := float64(currentRequests-lastRequestCount) / elapsed
 
-				// Calculate average latency from recent latencies
-				mc.mu.RLock()
-				var avgLatency float64
-				if len(mc.Latencies) > 0 {
-					// Get last 100 latencies for recent average
-					recentLatencies := mc.Latencies
-					if len(recentLatencies) > 100 {
-						recentLatencies = recentLatencies[len(recentLatencies)-100:]
-					}
-					var sum float64
-					for _, lat := range recentLatencies {
-						sum += lat
-					}
-					avgLatency = sum / float64(len(recentLatencies))
+				// Average latency of the requests completed in this interval;
+				// an interval without completions keeps the previous value
+				mc.mu.Lock()
+				if mc.intervalCount > 0 {
+					avgLatency = mc.intervalLatency / float64(mc.intervalCount)
+					mc.intervalLatency, mc.intervalCount = 0, 0
+				}
+				if mc.intervalPhaseCount > 0 {
+					phases = mc.intervalPhaseSum.average(mc.intervalPhaseCount)
+					mc.intervalPhaseSum, mc.intervalPhaseCount = PhaseTimings{}, 0
 				}
-				mc.mu.RUnlock()
+				statusClasses := mc.intervalStatus
+				mc.intervalStatus = StatusClassCounts{}
+				mc.mu.Unlock()
 
 				successRate := float64(0)
 				if currentRequests > 0 {
@@ -1331,11 +2311,15 @@ func (mc *MetricsCollector) collectTimeSeries(ctx context.Context) {
 				}
 
 				point := TimeSeriesPoint{
-					Timestamp:   now,
-					Requests:    currentRequests,
-					RPS:         rps,
-					AvgLatency:  avgLatency,
-					SuccessRate: successRate,
+					Timestamp:     now,
+					Requests:      currentRequests,
+					RPS:           rps,
+					AvgLatency:    avgLatency,
+					SuccessRate:   successRate,
+					ActiveUsers:   atomic.LoadInt64(&mc.ActiveUsers),
+					TargetLoad:    mc.TargetLoad(),
+					Phases:        phases,
+					StatusClasses: statusClasses,
 				}
 
 				mc.mu.Lock()
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	metricWriterBufferSize    = 20000                  // Samples queued before new ones are dropped
+	metricWriterBatchSize     = 1000                   // Samples written per transaction
+	metricWriterFlushInterval = 500 * time.Millisecond // Longest a queued sample waits for a flush
+)
+
+// MetricWriter persists a test's request metrics in the background. Users
+// hand samples over a buffered channel and never wait on the database; the
+// writer stores them in batched transactions. When the buffer is full the
+// sample is dropped and counted rather than slowing the load down.
+type MetricWriter struct {
+	db        *sql.DB
+	testRunID int64
+	samples   chan *RequestMetric
+	done      chan struct{}
+	dropped   atomic.Int64
+}
+
+// NewMetricWriter starts a writer for the given test run.
+func NewMetricWriter(db *sql.DB, testRunID int64) *MetricWriter {
+	w := &MetricWriter{
+		db:        db,
+		testRunID: testRunID,
+		samples:   make(chan *RequestMetric, metricWriterBufferSize),
+		done:      make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues a sample without blocking. It must not be called after Close.
+func (w *MetricWriter) Write(metric *RequestMetric) {
+	select {
+	case w.samples <- metric:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// Close flushes every queued sample and waits for the writer to finish.
+func (w *MetricWriter) Close() {
+	close(w.samples)
+	<-w.done
+}
+
+// Dropped returns the number of samples that were not persisted, either
+// because the buffer was full or because their batch failed to save.
+func (w *MetricWriter) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+func (w *MetricWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(metricWriterFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*RequestMetric, 0, metricWriterBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := SaveRequestMetrics(w.db, batch); err != nil {
+			slog.Error("Failed to save request metrics", "error", err, "test_id", w.testRunID, "samples", len(batch))
+			w.dropped.Add(int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case metric, ok := <-w.samples:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, metric)
+			if len(batch) >= metricWriterBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,9 @@
+-- Migration: Add open-model executor columns to test_runs
+-- Date: 2026-10
+-- Description: Track which executor drove a test, the offered load for the
+-- arrival-rate executor, and how many iterations were dropped because the
+-- worker pool was exhausted.
+
+ALTER TABLE test_runs ADD COLUMN executor TEXT NOT NULL DEFAULT 'closed_loop';
+ALTER TABLE test_runs ADD COLUMN target_rps REAL DEFAULT 0;
+ALTER TABLE test_runs ADD COLUMN dropped_iterations INTEGER DEFAULT 0;
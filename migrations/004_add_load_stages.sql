@@ -0,0 +1,7 @@
+-- Migration: Add stages column to test_runs
+-- Date: 2026-10
+-- Description: Persist multi-stage load profiles. Each stage is a JSON object
+-- with a duration in seconds and a target (users or requests per second,
+-- depending on the executor). NULL means the classic ramp-up/hold profile.
+
+ALTER TABLE test_runs ADD COLUMN stages TEXT;
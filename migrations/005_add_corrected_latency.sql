@@ -0,0 +1,9 @@
+-- Migration: Add coordinated-omission-corrected latency
+-- Date: 2026-10
+-- Description: Record each request's latency measured from its intended send
+-- time alongside the service time. Rows written before this migration have a
+-- NULL corrected_latency and are read back using their service time.
+
+ALTER TABLE request_metrics ADD COLUMN corrected_latency REAL;
+ALTER TABLE test_runs ADD COLUMN corrected_avg_latency REAL DEFAULT 0;
+ALTER TABLE test_runs ADD COLUMN corrected_max_latency REAL DEFAULT 0;
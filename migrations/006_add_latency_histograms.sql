@@ -0,0 +1,10 @@
+-- Migration: Persist latency histograms
+-- Date: 2026-10
+-- Description: Store the whole-test HDR latency histograms (service time and
+-- corrected latency) with each test run, along with the precision they were
+-- recorded at, so percentiles of completed tests can be reported exactly.
+-- Histograms use the standard compressed, base64-encoded HdrHistogram format.
+
+ALTER TABLE test_runs ADD COLUMN histogram_precision INTEGER DEFAULT 3;
+ALTER TABLE test_runs ADD COLUMN latency_histogram TEXT;
+ALTER TABLE test_runs ADD COLUMN corrected_latency_histogram TEXT;
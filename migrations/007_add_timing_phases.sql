@@ -0,0 +1,12 @@
+-- Migration: Add request timing phases
+-- Date: 2026-10
+-- Description: Record how long each request spent in DNS lookup, TCP connect,
+-- TLS handshake, waiting for the first byte and downloading the body, plus
+-- the per-test average breakdown. Older rows have NULL phases, read as 0.
+
+ALTER TABLE request_metrics ADD COLUMN dns_ms REAL;
+ALTER TABLE request_metrics ADD COLUMN connect_ms REAL;
+ALTER TABLE request_metrics ADD COLUMN tls_ms REAL;
+ALTER TABLE request_metrics ADD COLUMN ttfb_ms REAL;
+ALTER TABLE request_metrics ADD COLUMN download_ms REAL;
+ALTER TABLE test_runs ADD COLUMN phase_breakdown TEXT;
@@ -0,0 +1,8 @@
+-- Migration: Add error classification
+-- Date: 2026-10
+-- Description: Record the error category of each failed request and the
+-- per-test breakdown of failures by category and status code. Older rows have
+-- NULL categories and are classified from their status code when read.
+
+ALTER TABLE request_metrics ADD COLUMN error_category TEXT;
+ALTER TABLE test_runs ADD COLUMN error_breakdown TEXT;
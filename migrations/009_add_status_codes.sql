@@ -0,0 +1,7 @@
+-- Migration: Add status code distribution
+-- Date: 2026-10
+-- Description: Store the number of requests per status code for each test
+-- (0 for requests that received no response). Older tests have NULL and are
+-- counted from request_metrics when read.
+
+ALTER TABLE test_runs ADD COLUMN status_codes TEXT;
@@ -0,0 +1,7 @@
+-- Migration: Add dropped sample counter
+-- Date: 2026-10
+-- Description: Request metrics are now written in batches by a background
+-- writer per test. Record how many samples it could not persist, because its
+-- buffer was full or a batch failed to save.
+
+ALTER TABLE test_runs ADD COLUMN dropped_samples INTEGER DEFAULT 0;
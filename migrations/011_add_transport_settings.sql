@@ -0,0 +1,7 @@
+-- Migration: Add transport settings
+-- Date: 2026-10
+-- Description: Store the HTTP transport settings a test ran with (keep-alive,
+-- idle connection pool, HTTP/2 mode, timeouts, TLS verification and SNI).
+-- Older tests have NULL and used the default transport.
+
+ALTER TABLE test_runs ADD COLUMN transport TEXT;
@@ -0,0 +1,7 @@
+-- Migration: Add scenarios
+-- Date: 2026-10
+-- Description: Store a test's multi-step scenario and the per-step metrics it
+-- produced. Single-URL tests leave both columns NULL.
+
+ALTER TABLE test_runs ADD COLUMN scenario TEXT;
+ALTER TABLE test_runs ADD COLUMN step_metrics TEXT;
@@ -0,0 +1,6 @@
+-- Migration: Add request mix
+-- Date: 2026-10
+-- Description: Store a test's weighted request mix. Per-request metrics of a
+-- mix are stored in step_metrics. Other tests leave the column NULL.
+
+ALTER TABLE test_runs ADD COLUMN request_mix TEXT;
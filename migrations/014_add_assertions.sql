@@ -0,0 +1,8 @@
+-- Migration: Add assertions
+-- Date: 2026-10
+-- Description: Store the response assertions of a single-request test.
+-- Scenario and request mix steps keep their assertions in the scenario and
+-- request_mix columns. Older tests have NULL and used the default success
+-- rule (status below 400).
+
+ALTER TABLE test_runs ADD COLUMN assertions TEXT;
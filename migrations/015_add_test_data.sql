@@ -0,0 +1,15 @@
+-- Migration: Add test data
+-- Date: 2026-10
+-- Description: Store the CSV/JSONL data file that feeds a test's templates.
+-- The file itself goes into the new test_data table, one row per test run;
+-- test_runs.data keeps a JSON summary (format, mode, rows, columns, size and
+-- SHA-256 checksum) for reports and history.
+
+CREATE TABLE IF NOT EXISTS test_data (
+    test_run_id INTEGER PRIMARY KEY,
+    format TEXT NOT NULL,
+    content TEXT NOT NULL,
+    FOREIGN KEY (test_run_id) REFERENCES test_runs(id)
+);
+
+ALTER TABLE test_runs ADD COLUMN data TEXT;
@@ -0,0 +1,8 @@
+-- Migration: Add think time and pacing
+-- Date: 2026-10
+-- Description: Store the test-wide think time distribution and iteration
+-- pacing. Per-step think time and pacing are kept in the scenario and
+-- request_mix JSON.
+
+ALTER TABLE test_runs ADD COLUMN think_time TEXT;
+ALTER TABLE test_runs ADD COLUMN pacing_ms INTEGER DEFAULT 0;
@@ -0,0 +1,8 @@
+-- Migration: Add cookie sessions and redirect policy
+-- Date: 2026-10
+-- Description: Store the per-user cookie jar settings (without seed cookie
+-- values), the redirect policy and the number of sessions established.
+
+ALTER TABLE test_runs ADD COLUMN cookies TEXT;
+ALTER TABLE test_runs ADD COLUMN redirects TEXT;
+ALTER TABLE test_runs ADD COLUMN sessions_established INTEGER DEFAULT 0;
@@ -0,0 +1,6 @@
+-- Migration: Add OAuth2 token endpoint metrics
+-- Date: 2026-10
+-- Description: Store the requests a test sent to its OAuth2 token endpoint,
+-- kept apart from the target's metrics.
+
+ALTER TABLE test_runs ADD COLUMN token_metrics TEXT;
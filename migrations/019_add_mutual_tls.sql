@@ -0,0 +1,15 @@
+-- Migration: Add mutual TLS client certificates
+-- Date: 2026-10
+-- Description: Store each test's client certificate, key and CA bundle
+-- encrypted in the new test_credentials table, one row per test run.
+-- test_runs.tls keeps a summary (subject, issuer, expiry, CA count) and
+-- test_runs.tls_sessions the negotiated TLS versions and cipher suites.
+
+CREATE TABLE IF NOT EXISTS test_credentials (
+    test_run_id INTEGER PRIMARY KEY,
+    content TEXT NOT NULL,
+    FOREIGN KEY (test_run_id) REFERENCES test_runs(id)
+);
+
+ALTER TABLE test_runs ADD COLUMN tls TEXT;
+ALTER TABLE test_runs ADD COLUMN tls_sessions TEXT;
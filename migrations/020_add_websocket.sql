@@ -0,0 +1,8 @@
+-- Migration: Add WebSocket load testing
+-- Date: 2026-10
+-- Description: Store the connection and message settings of WebSocket tests
+-- and their connection and message counts. Message round trips are stored
+-- as request metrics like HTTP requests.
+
+ALTER TABLE test_runs ADD COLUMN websocket TEXT;
+ALTER TABLE test_runs ADD COLUMN websocket_metrics TEXT;
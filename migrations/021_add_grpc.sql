@@ -0,0 +1,8 @@
+-- Migration: Add gRPC load testing
+-- Date: 2026-10
+-- Description: Store the method and payload of gRPC tests and their calls
+-- per status code, and the gRPC status of each call in request_metrics.
+
+ALTER TABLE test_runs ADD COLUMN grpc TEXT;
+ALTER TABLE test_runs ADD COLUMN grpc_metrics TEXT;
+ALTER TABLE request_metrics ADD COLUMN grpc_status TEXT;
@@ -0,0 +1,8 @@
+-- Migration: Add GraphQL requests
+-- Date: 2026-10
+-- Description: Store the GraphQL operation of single-request tests and the
+-- requests, errors and latency of every GraphQL operation a test sent.
+-- Scenario steps keep their GraphQL operations in the scenario column.
+
+ALTER TABLE test_runs ADD COLUMN graphql TEXT;
+ALTER TABLE test_runs ADD COLUMN graphql_operations TEXT;
@@ -0,0 +1,8 @@
+-- Migration: Add streaming responses
+-- Date: 2026-10
+-- Description: Store the format and limits of stream tests, whose responses
+-- are read as SSE or line-delimited events, and their first-byte,
+-- first-event, inter-event gap and duration statistics.
+
+ALTER TABLE test_runs ADD COLUMN stream TEXT;
+ALTER TABLE test_runs ADD COLUMN stream_metrics TEXT;
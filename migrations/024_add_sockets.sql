@@ -0,0 +1,8 @@
+-- Migration: Add socket tests
+-- Date: 2026-10
+-- Description: Store the protocol, payload and response framing of raw TCP
+-- and UDP socket tests, and their connections, bytes sent and received, and
+-- connect and round-trip latency.
+
+ALTER TABLE test_runs ADD COLUMN socket TEXT;
+ALTER TABLE test_runs ADD COLUMN socket_metrics TEXT;
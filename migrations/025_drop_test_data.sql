@@ -0,0 +1,6 @@
+-- Migration: Drop stored test data
+-- Date: 2026-10
+-- Description: Data files are no longer kept after a test: nothing read them
+-- back. test_runs.data still holds each test's summary and checksum.
+
+DROP TABLE IF EXISTS test_data;
@@ -0,0 +1,6 @@
+-- Migration: Drop stored client certificates
+-- Date: 2026-10
+-- Description: Client certificates are no longer stored with tests: nothing
+-- read them back. test_runs.tls still holds each test's certificate summary.
+
+DROP TABLE IF EXISTS test_credentials;
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+const MaxRequestWeight = 1000 // Maximum weight of one request in a mix
+
+// requestPicker chooses a request of a mix with probability proportional to
+// its weight.
+type requestPicker struct {
+	cumulative []int // Running total of the weights, one entry per request
+	total      int
+}
+
+// validateRequestMix checks a request mix and normalizes it in place. Each
+// request is validated like a scenario step and must have a weight of 1 to
+// MaxRequestWeight.
+func validateRequestMix(requests []ScenarioStep, baseURL string) error {
+	if err := validateScenario(requests, baseURL); err != nil {
+		return err
+	}
+	for _, request := range requests {
+		if request.Weight < 1 || request.Weight > MaxRequestWeight {
+			return fmt.Errorf("step %q: weight must be between 1 and %d", request.Name, MaxRequestWeight)
+		}
+	}
+	return nil
+}
+
+func newRequestPicker(requests []ScenarioStep) *requestPicker {
+	picker := &requestPicker{cumulative: make([]int, len(requests))}
+	for i, request := range requests {
+		picker.total += request.Weight
+		picker.cumulative[i] = picker.total
+	}
+	return picker
+}
+
+// pick returns the index of the next request to send.
+func (p *requestPicker) pick() int {
+	n := rand.Intn(p.total)
+	return sort.SearchInts(p.cumulative, n+1)
+}
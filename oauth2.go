@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// OAuth2 auth types. The engine fetches a bearer token from the token URL and
+// refreshes it before it expires.
+const (
+	AuthOAuth2ClientCredentials = "oauth2_client_credentials"
+	AuthOAuth2Password          = "oauth2_password" // Resource owner password grant, using Username and Password
+)
+
+// Token caches.
+const (
+	TokenCacheTest = "test" // One token shared by all users (default)
+	TokenCacheUser = "user" // A token per virtual user
+)
+
+const (
+	maxTokenResponseBytes = 64 << 10
+	maxTokenRefreshMargin = time.Minute // Refresh at most this long before expiry
+	tokenFailureBackoff   = time.Second // A failed fetch is returned this long before the endpoint is tried again
+)
+
+// TokenMetrics summarises the requests sent to a test's OAuth2 token
+// endpoint. They are not counted in the target's metrics.
+type TokenMetrics struct {
+	Requests  int64        `json:"requests"`
+	Failures  int64        `json:"failures"`
+	Refreshes int64        `json:"refreshes"` // Tokens renewed with a refresh token
+	ErrorRate float64      `json:"error_rate"`
+	Latency   LatencyStats `json:"latency"`
+	LastError string       `json:"last_error,omitempty"`
+}
+
+// tokenCollector accumulates token endpoint requests while a test runs.
+type tokenCollector struct {
+	latencies *hdrhistogram.Histogram
+	requests  int64
+	failures  int64
+	refreshes int64
+	lastError string
+}
+
+// tokenResponse is the token endpoint's JSON response (RFC 6749 section 5.1).
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Error        string `json:"error"`
+	Description  string `json:"error_description"`
+}
+
+// tokenSource fetches and caches the OAuth2 token of a test or of one user.
+// Concurrent callers wait for a single fetch, sent without holding mu.
+type tokenSource struct {
+	cfg     *AuthConfig
+	client  *http.Client
+	metrics *MetricsCollector
+
+	mu           sync.Mutex
+	token        string
+	refreshToken string
+	refreshAt    time.Time     // Zero when the token does not expire
+	fetching     chan struct{} // Closed when the fetch in flight completes
+	err          error         // The last fetch's failure, returned until retryAt
+	retryAt      time.Time
+}
+
+// isOAuth2 reports whether an auth type fetches tokens from a token endpoint.
+func isOAuth2(authType string) bool {
+	return authType == AuthOAuth2ClientCredentials || authType == AuthOAuth2Password
+}
+
+// validateOAuth2Config checks the OAuth2 settings of a test's auth and fills
+// in defaults. The token URL passes the same SSRF checks as the target.
+func validateOAuth2Config(cfg *AuthConfig) error {
+	if cfg.TokenURL == "" {
+		return fmt.Errorf("token_url is required")
+	}
+	if err := validateHost(cfg.TokenURL); err != nil {
+		return fmt.Errorf("token_url: %v", err)
+	}
+	if parsed, err := url.Parse(cfg.TokenURL); err != nil || parsed.Host == "" {
+		return fmt.Errorf("token_url must be an absolute http or https url")
+	}
+	if cfg.ClientID == "" {
+		return fmt.Errorf("client_id is required")
+	}
+	if cfg.Type == AuthOAuth2Password && (cfg.Username == "" || cfg.Password == "") {
+		return fmt.Errorf("username and password are required for the password grant")
+	}
+	if cfg.TokenCache == "" {
+		cfg.TokenCache = TokenCacheTest
+	}
+	if cfg.TokenCache != TokenCacheTest && cfg.TokenCache != TokenCacheUser {
+		return fmt.Errorf("token_cache must be one of %v", []string{TokenCacheTest, TokenCacheUser})
+	}
+	return nil
+}
+
+// newTokenSource creates the token source of a test. Token requests use the
+// test's transport but never follow redirects.
+func newTokenSource(cfg *AuthConfig, testCtx *TestContext) *tokenSource {
+	return &tokenSource{
+		cfg: cfg,
+		client: &http.Client{
+			Transport: testCtx.Transport,
+			Timeout:   testCtx.TestRun.Transport.RequestTimeout(),
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		metrics: testCtx.Metrics,
+	}
+}
+
+// forUser returns an empty source with the same settings, for a per-user
+// cache.
+func (s *tokenSource) forUser() *tokenSource {
+	return &tokenSource{cfg: s.cfg, client: s.client, metrics: s.metrics}
+}
+
+// tokenSourceFor returns the source of a user's tokens, or nil when the test
+// does not use OAuth2.
+func (testCtx *TestContext) tokenSourceFor(user *virtualUser) *tokenSource {
+	if testCtx.Tokens == nil || testCtx.Tokens.cfg.TokenCache != TokenCacheUser {
+		return testCtx.Tokens
+	}
+	if user.tokens == nil {
+		user.tokens = testCtx.Tokens.forUser()
+	}
+	return user.tokens
+}
+
+// Token returns a valid access token, fetching a new one when there is none
+// or it is about to expire. Callers arriving during a fetch wait for its
+// result, and a failed fetch is returned to every caller for
+// tokenFailureBackoff rather than retried by each.
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	for {
+		s.mu.Lock()
+		if s.token != "" && (s.refreshAt.IsZero() || time.Now().Before(s.refreshAt)) {
+			token := s.token
+			s.mu.Unlock()
+			return token, nil
+		}
+		if s.err != nil && time.Now().Before(s.retryAt) {
+			err := s.err
+			s.mu.Unlock()
+			return "", err
+		}
+		if fetching := s.fetching; fetching != nil {
+			s.mu.Unlock()
+			select {
+			case <-fetching:
+				continue
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		fetching := make(chan struct{})
+		s.fetching = fetching
+		refreshToken := s.refreshToken
+		s.mu.Unlock()
+
+		token, refreshAt, err := s.renew(ctx, refreshToken)
+
+		s.mu.Lock()
+		s.fetching = nil
+		close(fetching)
+		if err != nil {
+			s.token, s.refreshToken = "", ""
+			if ctx.Err() == nil {
+				s.err, s.retryAt = err, time.Now().Add(tokenFailureBackoff)
+			}
+			s.mu.Unlock()
+			return "", err
+		}
+		s.token, s.refreshToken, s.refreshAt = token.AccessToken, token.RefreshToken, refreshAt
+		s.err = nil
+		s.mu.Unlock()
+		return token.AccessToken, nil
+	}
+}
+
+// renew fetches a new token, preferring the refresh token when the server
+// issued one and falling back to the configured grant if it is rejected. It
+// returns the token and when to refresh it.
+func (s *tokenSource) renew(ctx context.Context, refreshToken string) (*tokenResponse, time.Time, error) {
+	if refreshToken != "" {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}
+		if token, refreshAt, err := s.fetch(ctx, form, true); err == nil {
+			if token.RefreshToken == "" {
+				token.RefreshToken = refreshToken
+			}
+			return token, refreshAt, nil
+		}
+	}
+
+	form := url.Values{}
+	switch s.cfg.Type {
+	case AuthOAuth2ClientCredentials:
+		form.Set("grant_type", "client_credentials")
+	case AuthOAuth2Password:
+		form.Set("grant_type", "password")
+		form.Set("username", s.cfg.Username)
+		form.Set("password", s.cfg.Password)
+	}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	if s.cfg.Audience != "" {
+		form.Set("audience", s.cfg.Audience)
+	}
+	return s.fetch(ctx, form, false)
+}
+
+// invalidate drops token if it is still the cached one, so the next request
+// fetches a new token. It is called when the target rejects a token.
+func (s *tokenSource) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+// fetch requests a token, returning it and when to refresh it: a tenth of its
+// lifetime before it expires, or the zero time when it does not.
+func (s *tokenSource) fetch(ctx context.Context, form url.Values, refresh bool) (*tokenResponse, time.Time, error) {
+	if s.cfg.ClientAuthInBody {
+		form.Set("client_id", s.cfg.ClientID)
+		if s.cfg.ClientSecret != "" {
+			form.Set("client_secret", s.cfg.ClientSecret)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !s.cfg.ClientAuthInBody {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	start := time.Now()
+	token, err := s.exchange(req)
+	s.metrics.RecordToken(time.Since(start).Seconds()*1000, err, refresh)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var refreshAt time.Time
+	if token.ExpiresIn > 0 {
+		lifetime := time.Duration(token.ExpiresIn) * time.Second
+		margin := lifetime / 10
+		if margin > maxTokenRefreshMargin {
+			margin = maxTokenRefreshMargin
+		}
+		refreshAt = start.Add(lifetime - margin)
+	}
+	return token, refreshAt, nil
+}
+
+// exchange sends a token request and decodes the response.
+func (s *tokenSource) exchange(req *http.Request) (*tokenResponse, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	var token tokenResponse
+	decodeErr := json.Unmarshal(body, &token)
+	if resp.StatusCode != http.StatusOK {
+		if token.Error != "" {
+			return nil, fmt.Errorf("token endpoint returned HTTP %d: %s %s", resp.StatusCode, token.Error, token.Description)
+		}
+		return nil, fmt.Errorf("token endpoint returned HTTP %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("invalid token response: %v", decodeErr)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("token response has no access_token")
+	}
+	return &token, nil
+}
+
+// RecordToken adds a token endpoint request to the test's token metrics.
+func (mc *MetricsCollector) RecordToken(latency float64, err error, refresh bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.tokens == nil {
+		mc.tokens = &tokenCollector{latencies: newLatencyHistogram(mc.precision)}
+	}
+	recordLatency(mc.tokens.latencies, latency)
+	mc.tokens.requests++
+	if err != nil {
+		mc.tokens.failures++
+		mc.tokens.lastError = truncateSample(errorSample(err))
+	} else if refresh {
+		mc.tokens.refreshes++
+	}
+}
+
+// TokenMetrics summarises the token endpoint requests so far, or returns nil
+// when none were sent.
+func (mc *MetricsCollector) TokenMetrics() *TokenMetrics {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	if mc.tokens == nil {
+		return nil
+	}
+	metrics := &TokenMetrics{
+		Requests:  mc.tokens.requests,
+		Failures:  mc.tokens.failures,
+		Refreshes: mc.tokens.refreshes,
+		Latency:   histogramStats(mc.tokens.latencies),
+		LastError: mc.tokens.lastError,
+	}
+	if metrics.Requests > 0 {
+		metrics.ErrorRate = float64(metrics.Failures) / float64(metrics.Requests) * 100
+	}
+	return metrics
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer is a stand-in token endpoint. It issues numbered tokens with a
+// refresh token, and fails every request while status is not 200.
+type tokenServer struct {
+	*httptest.Server
+	requests  atomic.Int64
+	refreshes atomic.Int64
+	status    atomic.Int64
+	release   chan struct{} // When set, requests wait for it to be closed
+}
+
+func newTokenServer(t *testing.T) *tokenServer {
+	ts := &tokenServer{}
+	ts.status.Store(http.StatusOK)
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := ts.requests.Add(1)
+		if ts.release != nil {
+			<-ts.release
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if status := int(ts.status.Load()); status != http.StatusOK {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		if r.PostForm.Get("grant_type") == "refresh_token" {
+			if r.PostForm.Get("refresh_token") != "refresh" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			ts.refreshes.Add(1)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("token-%d", n),
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"refresh_token": "refresh",
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newTestTokenSource(url string) *tokenSource {
+	testCtx := &TestContext{
+		TestRun:   &TestRun{},
+		Transport: http.DefaultTransport,
+		Metrics:   NewMetricsCollector(DefaultHistogramPrecision),
+	}
+	cfg := &AuthConfig{
+		Type:       AuthOAuth2ClientCredentials,
+		TokenURL:   url,
+		ClientID:   "client",
+		TokenCache: TokenCacheTest,
+	}
+	return newTokenSource(cfg, testCtx)
+}
+
+func TestTokenSourceCachesToken(t *testing.T) {
+	ts := newTokenServer(t)
+	source := newTestTokenSource(ts.URL)
+
+	for i := 0; i < 5; i++ {
+		token, err := source.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token-1" {
+			t.Fatalf("token = %q, want token-1", token)
+		}
+	}
+	if n := ts.requests.Load(); n != 1 {
+		t.Errorf("token requests = %d, want 1", n)
+	}
+	if m := source.metrics.TokenMetrics(); m == nil || m.Requests != 1 || m.Failures != 0 {
+		t.Errorf("token metrics = %+v, want 1 request", m)
+	}
+}
+
+func TestTokenSourceSingleFetch(t *testing.T) {
+	ts := newTokenServer(t)
+	ts.release = make(chan struct{})
+	source := newTestTokenSource(ts.URL)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _ = source.Token(context.Background())
+		}(i)
+	}
+	for ts.requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(ts.release)
+	wg.Wait()
+
+	if n := ts.requests.Load(); n != 1 {
+		t.Errorf("token requests = %d, want 1", n)
+	}
+	for _, token := range tokens {
+		if token != "token-1" {
+			t.Fatalf("tokens = %v, want token-1 for every caller", tokens)
+		}
+	}
+}
+
+func TestTokenSourceRefresh(t *testing.T) {
+	ts := newTokenServer(t)
+	source := newTestTokenSource(ts.URL)
+
+	if _, err := source.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if source.refreshAt.IsZero() || time.Until(source.refreshAt) > time.Hour-maxTokenRefreshMargin {
+		t.Fatalf("refreshAt = %v, want a minute before expiry", source.refreshAt)
+	}
+
+	// Expiring tokens are renewed with the refresh token
+	source.refreshAt = time.Now().Add(-time.Second)
+	token, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-2" || ts.refreshes.Load() != 1 {
+		t.Errorf("token = %q after %d refreshes, want token-2 after 1", token, ts.refreshes.Load())
+	}
+	if m := source.metrics.TokenMetrics(); m.Refreshes != 1 {
+		t.Errorf("refreshes = %d, want 1", m.Refreshes)
+	}
+
+	// A rejected refresh token falls back to the configured grant
+	source.refreshToken = "revoked"
+	source.refreshAt = time.Now().Add(-time.Second)
+	token, err = source.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-4" || source.refreshToken != "refresh" {
+		t.Errorf("token = %q with refresh token %q, want token-4 with refresh", token, source.refreshToken)
+	}
+}
+
+func TestTokenSourceInvalidate(t *testing.T) {
+	ts := newTokenServer(t)
+	source := newTestTokenSource(ts.URL)
+
+	first, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.invalidate(first)
+	second, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatalf("token = %q after a 401, want a new token", second)
+	}
+
+	// A stale rejection must not drop the newer token
+	source.invalidate(first)
+	if token, _ := source.Token(context.Background()); token != second {
+		t.Errorf("token = %q, want %q", token, second)
+	}
+	if n := ts.requests.Load(); n != 2 {
+		t.Errorf("token requests = %d, want 2", n)
+	}
+}
+
+func TestTokenSourceFailureBackoff(t *testing.T) {
+	ts := newTokenServer(t)
+	ts.status.Store(http.StatusUnauthorized)
+	source := newTestTokenSource(ts.URL)
+
+	_, first := source.Token(context.Background())
+	_, second := source.Token(context.Background())
+	if first == nil || second != first {
+		t.Fatalf("errors = %v, %v, want the same failure twice", first, second)
+	}
+	if n := ts.requests.Load(); n != 1 {
+		t.Errorf("token requests = %d during the backoff, want 1", n)
+	}
+
+	// The endpoint is tried again once the backoff has passed
+	ts.status.Store(http.StatusOK)
+	source.retryAt = time.Now()
+	if token, err := source.Token(context.Background()); err != nil || token != "token-2" {
+		t.Errorf("Token() = %q, %v after the backoff, want token-2", token, err)
+	}
+	if m := source.metrics.TokenMetrics(); m.Requests != 2 || m.Failures != 1 {
+		t.Errorf("token metrics = %+v, want 2 requests and 1 failure", m)
+	}
+}
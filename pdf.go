@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"math"
+	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
@@ -71,7 +73,25 @@ type timeSeriesSummary struct {
 	LatencyPercentiles map[string]float64
 }
 
-func GeneratePDFReport(testRun *TestRun, timeSeries []TimeSeriesPoint) ([]byte, error) {
+// ReportData carries aggregates that are computed from request samples rather
+// than stored on the test run.
+type ReportData struct {
+	ServiceLatency   LatencyStats
+	CorrectedLatency LatencyStats
+	Phases           PhaseTimings
+	Errors           ErrorBreakdown
+	StatusCodes      map[int]int64
+	Steps            []StepMetrics
+	Tokens           *TokenMetrics
+	TLSSessions      []TLSSession
+	WebSocket        *WebSocketMetrics
+	GRPC             *GRPCMetrics
+	GraphQL          []GraphQLMetrics
+	Stream           *StreamMetrics
+	Socket           *SocketMetrics
+}
+
+func GeneratePDFReport(testRun *TestRun, timeSeries []TimeSeriesPoint, data ReportData) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.SetMargins(15, 20, 15)
 	pdf.SetAutoPageBreak(true, 20)
@@ -79,9 +99,22 @@ func GeneratePDFReport(testRun *TestRun, timeSeries []TimeSeriesPoint) ([]byte,
 
 	renderTitle(pdf, testRun)
 	renderOverview(pdf, testRun)
+	renderLoadProfile(pdf, testRun)
 
 	summary := analyzeTimeSeries(timeSeries)
-	renderMetricCards(pdf, testRun, summary)
+	renderMetricCards(pdf, testRun, summary, data)
+	renderLatencyDistribution(pdf, data)
+	renderStepMetrics(pdf, data.Steps, testRun.RequestMix)
+	renderGraphQLOperations(pdf, data.GraphQL)
+	renderTokenMetrics(pdf, data.Tokens)
+	renderTLSSessions(pdf, data.TLSSessions)
+	renderWebSocketMetrics(pdf, data.WebSocket)
+	renderGRPCMetrics(pdf, data.GRPC)
+	renderStreamMetrics(pdf, data.Stream)
+	renderSocketMetrics(pdf, data.Socket)
+	renderPhaseBreakdown(pdf, data.Phases)
+	renderStatusCodes(pdf, data.StatusCodes)
+	renderErrorBreakdown(pdf, data.Errors)
 
 	if summary.HasData {
 		renderTimeSeriesInsights(pdf, summary)
@@ -131,19 +164,173 @@ func renderOverview(pdf *gofpdf.Fpdf, testRun *TestRun) {
 
 	rows := []kvRow{
 		{Label: "Status", Value: titleCase(testRun.Status)},
-		{Label: "Concurrent Users", Value: fmt.Sprintf("%d users", testRun.TotalUsers)},
-		{Label: "Ramp-up Time", Value: formatDurationFromSeconds(testRun.RampUpSec)},
-		{Label: "Planned Duration", Value: formatDurationFromSeconds(testRun.Duration)},
-		{Label: "Actual Duration", Value: formatActualDuration(testRun)},
-		{Label: "Run Window", Value: formatTimeWindow(testRun.StartedAt, testRun.CompletedAt)},
+	}
+
+	if testRun.Executor == ExecutorArrivalRate {
+		rows = append(rows,
+			kvRow{Label: "Executor", Value: "Arrival rate (open model)"},
+			kvRow{Label: "Target RPS", Value: formatFloat(testRun.TargetRPS, 2)},
+			kvRow{Label: "Worker Pool", Value: fmt.Sprintf("%d workers", testRun.TotalUsers)},
+			kvRow{Label: "Dropped Iterations", Value: formatWithCommas(testRun.DroppedIterations)},
+		)
+	} else {
+		rows = append(rows,
+			kvRow{Label: "Executor", Value: "Closed loop"},
+			kvRow{Label: "Concurrent Users", Value: fmt.Sprintf("%d users", testRun.TotalUsers)},
+		)
+	}
+
+	rows = append(rows,
+		kvRow{Label: "Ramp-up Time", Value: formatDurationFromSeconds(testRun.RampUpSec)},
+		kvRow{Label: "Planned Duration", Value: formatDurationFromSeconds(testRun.Duration)},
+		kvRow{Label: "Actual Duration", Value: formatActualDuration(testRun)},
+		kvRow{Label: "Run Window", Value: formatTimeWindow(testRun.StartedAt, testRun.CompletedAt)},
+	)
+	if testRun.Transport.HTTP2 != "" {
+		rows = append(rows, kvRow{Label: "HTTP Transport", Value: formatTransport(testRun.Transport)})
+	}
+	if testRun.Data != nil {
+		rows = append(rows, kvRow{Label: "Test Data", Value: formatTestData(testRun.Data)})
+	}
+	if testRun.ThinkTime != nil {
+		rows = append(rows, kvRow{Label: "Think Time", Value: formatThinkTime(testRun.ThinkTime)})
+	}
+	if testRun.PacingMs > 0 {
+		rows = append(rows, kvRow{Label: "Pacing", Value: fmt.Sprintf("%d ms per iteration", testRun.PacingMs)})
+	}
+	if testRun.Cookies != nil && testRun.Cookies.Enabled {
+		rows = append(rows, kvRow{Label: "Cookies", Value: fmt.Sprintf("Per-user jar, %d seed cookies, %s sessions established",
+			len(testRun.Cookies.Seed), formatWithCommas(testRun.SessionsEstablished))})
+	}
+	if redirects := formatRedirects(testRun.Redirects); redirects != "" {
+		rows = append(rows, kvRow{Label: "Redirects", Value: redirects})
+	}
+	if testRun.TLS != nil {
+		rows = append(rows, kvRow{Label: "Mutual TLS", Value: formatClientTLS(testRun.TLS)})
+	}
+	if testRun.WebSocket != nil {
+		rows = append(rows, kvRow{Label: "WebSocket", Value: formatWebSocket(testRun.WebSocket)})
+	}
+	if testRun.GRPC != nil {
+		rows = append(rows, kvRow{Label: "gRPC", Value: formatGRPC(testRun.GRPC)})
+	}
+	if testRun.GraphQL != nil {
+		rows = append(rows, kvRow{Label: "GraphQL", Value: formatGraphQL(testRun.GraphQL)})
+	}
+	if testRun.Stream != nil {
+		rows = append(rows, kvRow{Label: "Stream", Value: formatStream(testRun.Stream)})
+	}
+	if testRun.Socket != nil {
+		rows = append(rows, kvRow{Label: "Socket", Value: formatSocket(testRun.Socket)})
+	}
+	// Stored per-request samples feed the historical time series, so flag
+	// tests where some of them were lost
+	if testRun.DroppedSamples > 0 {
+		rows = append(rows, kvRow{Label: "Dropped Samples", Value: formatWithCommas(testRun.DroppedSamples)})
 	}
 
 	renderKeyValueRows(pdf, rows)
 }
 
-func renderMetricCards(pdf *gofpdf.Fpdf, testRun *TestRun, summary timeSeriesSummary) {
+func renderLoadProfile(pdf *gofpdf.Fpdf, testRun *TestRun) {
+	profile := testRun.LoadProfile()
+	unit := "users"
+	if testRun.Executor == ExecutorArrivalRate {
+		unit = "req/s"
+	}
+
+	renderSectionHeader(pdf, "Load Profile")
+
+	colWidths := []float64{20, 50, 50, 60}
+	headers := []string{"Stage", "Window", "Duration", "Target"}
+
+	renderTableHeader(pdf, colWidths, headers)
+
+	pdf.SetFont("Arial", "", 8)
+	offset := 0
+	peak := 0.0
+	for idx, stage := range profile {
+		cells := []string{
+			strconv.Itoa(idx + 1),
+			fmt.Sprintf("%s - %s", formatDurationFromSeconds(offset), formatDurationFromSeconds(offset+stage.Duration)),
+			formatDurationFromSeconds(stage.Duration),
+			fmt.Sprintf("%s %s", formatFloat(stage.Target, 0), unit),
+		}
+		for col := range cells {
+			ln := 0
+			if col == len(cells)-1 {
+				ln = 1
+			}
+			pdf.CellFormat(colWidths[col], 5, cells[col], "1", ln, "C", false, 0, "")
+		}
+		offset += stage.Duration
+		peak = math.Max(peak, stage.Target)
+	}
+	pdf.Ln(4)
+
+	if offset <= 0 || peak <= 0 {
+		return
+	}
+
+	// Timeline: target load over the planned duration
+	chartHeight := 40.0
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+chartHeight+10 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	left, _, _, _ := pdf.GetMargins()
+	x0 := left + 12
+	y0 := pdf.GetY()
+	chartWidth := 180.0 - 12
+
+	pdf.SetDrawColor(colorBorder.R, colorBorder.G, colorBorder.B)
+	pdf.SetFillColor(colorPanel.R, colorPanel.G, colorPanel.B)
+	pdf.Rect(x0, y0, chartWidth, chartHeight, "FD")
+
+	pdf.SetFont("Arial", "", 7)
+	pdf.SetTextColor(colorMuted.R, colorMuted.G, colorMuted.B)
+	pdf.SetXY(left, y0-1)
+	pdf.CellFormat(11, 3, formatFloat(peak, 0), "", 0, "R", false, 0, "")
+	pdf.SetXY(left, y0+chartHeight-2)
+	pdf.CellFormat(11, 3, "0", "", 0, "R", false, 0, "")
+	pdf.SetXY(x0, y0+chartHeight+1)
+	pdf.CellFormat(chartWidth/2, 3, "0s", "", 0, "L", false, 0, "")
+	pdf.CellFormat(chartWidth/2, 3, formatDurationFromSeconds(offset), "", 0, "R", false, 0, "")
+
+	pointX := func(seconds int) float64 {
+		return x0 + chartWidth*float64(seconds)/float64(offset)
+	}
+	pointY := func(target float64) float64 {
+		return y0 + chartHeight - chartHeight*target/peak
+	}
+
+	pdf.SetDrawColor(colorPrimary.R, colorPrimary.G, colorPrimary.B)
+	pdf.SetLineWidth(0.6)
+	prevX, prevY := pointX(0), pointY(0)
+	elapsed := 0
+	for _, stage := range profile {
+		elapsed += stage.Duration
+		x, y := pointX(elapsed), pointY(stage.Target)
+		pdf.Line(prevX, prevY, x, y)
+		prevX, prevY = x, y
+	}
+	pdf.SetLineWidth(0.2)
+
+	pdf.SetTextColor(colorText.R, colorText.G, colorText.B)
+	pdf.SetXY(left, y0+chartHeight+6)
+}
+
+func renderMetricCards(pdf *gofpdf.Fpdf, testRun *TestRun, summary timeSeriesSummary, data ReportData) {
 	renderSectionHeader(pdf, "Performance Summary")
 
+	// Prefer whole-test percentiles; fall back to the time-series estimate
+	p50, p95, p99 := latencyPercentile(summary, "p50"), latencyPercentile(summary, "p95"), latencyPercentile(summary, "p99")
+	if data.ServiceLatency.Count > 0 {
+		p50, p95, p99 = data.ServiceLatency.P50, data.ServiceLatency.P95, data.ServiceLatency.P99
+	}
+
 	successRate := calculatePercentage(testRun.SuccessCount, testRun.TotalRequests)
 	errorRate := calculatePercentage(testRun.ErrorCount, testRun.TotalRequests)
 
@@ -170,8 +357,8 @@ func renderMetricCards(pdf *gofpdf.Fpdf, testRun *TestRun, summary timeSeriesSum
 		},
 		{
 			Label:  "P95 Latency",
-			Value:  formatLatencyValue(latencyPercentile(summary, "p95")),
-			Helper: fmt.Sprintf("P99 %s · P50 %s", formatLatencyValue(latencyPercentile(summary, "p99")), formatLatencyValue(latencyPercentile(summary, "p50"))),
+			Value:  formatLatencyValue(p95),
+			Helper: fmt.Sprintf("P99 %s · P50 %s", formatLatencyValue(p99), formatLatencyValue(p50)),
 		},
 		{
 			Label:  "Peak RPS",
@@ -187,8 +374,16 @@ func renderMetricCards(pdf *gofpdf.Fpdf, testRun *TestRun, summary timeSeriesSum
 	colWidth := (usableWidth - gap) / 2
 	cardHeight := 24.0
 	startX := pdf.GetX()
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
 
 	for idx, metric := range metrics {
+		// Keep each row of cards on one page
+		if idx%2 == 0 && pdf.GetY()+cardHeight > pageHeight-bottom {
+			pdf.AddPage()
+			pdf.SetX(startX)
+		}
+
 		x := pdf.GetX()
 		y := pdf.GetY()
 
@@ -227,6 +422,631 @@ func renderMetricCards(pdf *gofpdf.Fpdf, testRun *TestRun, summary timeSeriesSum
 	pdf.Ln(2)
 }
 
+// renderLatencyDistribution compares the service time of each request with its
+// latency measured from the intended send time. A wide gap means requests were
+// queued behind slow responses and the service time alone understates latency.
+func renderLatencyDistribution(pdf *gofpdf.Fpdf, data ReportData) {
+	if data.ServiceLatency.Count == 0 {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+70 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	renderSectionHeader(pdf, "Latency Distribution")
+
+	colWidths := []float64{45, 45, 45, 45}
+	headers := []string{"Statistic", "Service Time", "Corrected", "Difference"}
+
+	renderTableHeader(pdf, colWidths, headers)
+
+	service, corrected := data.ServiceLatency, data.CorrectedLatency
+	rows := []struct {
+		label              string
+		service, corrected float64
+	}{
+		{"Average", service.Avg, corrected.Avg},
+		{"P50", service.P50, corrected.P50},
+		{"P90", service.P90, corrected.P90},
+		{"P95", service.P95, corrected.P95},
+		{"P99", service.P99, corrected.P99},
+		{"P99.9", service.P999, corrected.P999},
+		{"Max", service.Max, corrected.Max},
+	}
+
+	pdf.SetFont("Arial", "", 8)
+	pdf.SetFillColor(255, 255, 255)
+	for _, row := range rows {
+		cells := []string{
+			row.label,
+			formatLatencyValue(row.service),
+			formatLatencyValue(row.corrected),
+			formatLatencyValue(math.Max(row.corrected-row.service, 0)),
+		}
+		for col, cell := range cells {
+			ln := 0
+			if col == len(cells)-1 {
+				ln = 1
+			}
+			pdf.CellFormat(colWidths[col], 5, cell, "1", ln, "C", false, 0, "")
+		}
+	}
+
+	pdf.SetFont("Arial", "I", 8)
+	pdf.SetTextColor(colorMuted.R, colorMuted.G, colorMuted.B)
+	pdf.Ln(1)
+	pdf.MultiCell(0, 4, fmt.Sprintf("Computed from %s samples. Corrected latency is measured from when each request was scheduled to be sent, so it includes time spent waiting behind slow responses.", formatWithCommas(int64(service.Count))), "", "L", false)
+	pdf.SetTextColor(colorText.R, colorText.G, colorText.B)
+	pdf.Ln(3)
+}
+
+// renderPhaseBreakdown draws the average request as a stacked bar of its
+// timing phases, followed by a table of each phase's share.
+func renderPhaseBreakdown(pdf *gofpdf.Fpdf, phases PhaseTimings) {
+	total := phases.Total()
+	if total <= 0 {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+70 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	renderSectionHeader(pdf, "Request Timing Phases")
+
+	segments := []struct {
+		label string
+		value float64
+		color pdfColor
+	}{
+		{"DNS Lookup", phases.DNS, pdfColor{14, 165, 233}},
+		{"TCP Connect", phases.Connect, pdfColor{16, 185, 129}},
+		{"TLS Handshake", phases.TLS, pdfColor{245, 158, 11}},
+		{"Time to First Byte", phases.TTFB, colorPrimary},
+		{"Download", phases.Download, pdfColor{139, 92, 246}},
+	}
+
+	left := pdf.GetX()
+	y := pdf.GetY()
+	barWidth := 180.0
+	barHeight := 8.0
+
+	x := left
+	for _, segment := range segments {
+		width := segment.value / total * barWidth
+		if width <= 0 {
+			continue
+		}
+		pdf.SetFillColor(segment.color.R, segment.color.G, segment.color.B)
+		pdf.Rect(x, y, width, barHeight, "F")
+		x += width
+	}
+	pdf.SetDrawColor(colorBorder.R, colorBorder.G, colorBorder.B)
+	pdf.Rect(left, y, barWidth, barHeight, "D")
+	pdf.SetXY(left, y+barHeight+4)
+
+	colWidths := []float64{80, 50, 50}
+	headers := []string{"Phase", "Average", "Share"}
+
+	renderTableHeader(pdf, colWidths, headers)
+
+	pdf.SetFont("Arial", "", 8)
+	for _, segment := range segments {
+		rowY := pdf.GetY()
+		pdf.SetFillColor(segment.color.R, segment.color.G, segment.color.B)
+		pdf.Rect(left+3, rowY+1.5, 2, 2, "F")
+		pdf.CellFormat(colWidths[0], 5, "      "+segment.label, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(colWidths[1], 5, fmt.Sprintf("%.2f ms", segment.value), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[2], 5, formatPercentage(segment.value/total*100, 1), "1", 1, "C", false, 0, "")
+	}
+
+	pdf.SetFont("Arial", "I", 8)
+	pdf.SetTextColor(colorMuted.R, colorMuted.G, colorMuted.B)
+	pdf.Ln(1)
+	pdf.MultiCell(0, 4, "Averaged over requests that received a response. Reused connections skip DNS, connect and TLS, so those phases are averaged down by keep-alive.", "", "L", false)
+	pdf.SetTextColor(colorText.R, colorText.G, colorText.B)
+	pdf.Ln(3)
+}
+
+// renderStepMetrics compares the scenario's steps, or the requests of a
+// request mix with their configured and actual share of the traffic.
+func renderStepMetrics(pdf *gofpdf.Fpdf, steps []StepMetrics, mix []ScenarioStep) {
+	if len(steps) == 0 {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+20+float64(len(steps))*5 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	title := "Scenario Steps"
+	colWidths := []float64{44, 22, 22, 23, 23, 23, 23}
+	headers := []string{"Step", "Requests", "Errors", "Average", "P50", "P95", "P99"}
+	weights := make(map[string]float64, len(mix))
+	if len(mix) > 0 {
+		title = "Request Mix"
+		colWidths = []float64{36, 16, 16, 20, 24, 17, 17, 17, 17}
+		headers = []string{"Request", "Weight", "Share", "Requests", "Errors", "Average", "P50", "P95", "P99"}
+		var total int
+		for _, request := range mix {
+			total += request.Weight
+		}
+		for _, request := range mix {
+			weights[request.Name] = float64(request.Weight) / float64(total) * 100
+		}
+	}
+
+	renderSectionHeader(pdf, title)
+	renderTableHeader(pdf, colWidths, headers)
+
+	pdf.SetFont("Arial", "", 8)
+	for _, step := range steps {
+		name := step.Name
+		for pdf.GetStringWidth(name) > colWidths[0]-4 && len(name) > 3 {
+			name = name[:len(name)-4] + "..."
+		}
+		cells := []string{name}
+		if len(mix) > 0 {
+			cells = append(cells, formatPercentage(weights[step.Name], 1), formatPercentage(step.Share, 1))
+		}
+		cells = append(cells,
+			formatWithCommas(step.TotalRequests),
+			fmt.Sprintf("%s (%s)", formatWithCommas(step.ErrorCount), formatPercentage(step.ErrorRate, 1)),
+			fmt.Sprintf("%.2f ms", step.Latency.Avg),
+			fmt.Sprintf("%.2f ms", step.Latency.P50),
+			fmt.Sprintf("%.2f ms", step.Latency.P95),
+			fmt.Sprintf("%.2f ms", step.Latency.P99),
+		)
+		for col, cell := range cells {
+			ln, align := 0, "C"
+			if col == 0 {
+				align = "L"
+			}
+			if col == len(cells)-1 {
+				ln = 1
+			}
+			pdf.CellFormat(colWidths[col], 5, cell, "1", ln, align, false, 0, "")
+		}
+	}
+	pdf.Ln(4)
+}
+
+// renderTokenMetrics summarises the requests sent to the OAuth2 token
+// endpoint, which are not part of the target's metrics.
+func renderTokenMetrics(pdf *gofpdf.Fpdf, tokens *TokenMetrics) {
+	if tokens == nil {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+35 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	renderSectionHeader(pdf, "Token Endpoint")
+	colWidths := []float64{22, 30, 22, 21, 21, 21, 21, 22}
+	renderTableHeader(pdf, colWidths, []string{"Requests", "Failures", "Refreshes", "Average", "P50", "P95", "P99", "Max"})
+
+	pdf.SetFont("Arial", "", 8)
+	cells := []string{
+		formatWithCommas(tokens.Requests),
+		fmt.Sprintf("%s (%s)", formatWithCommas(tokens.Failures), formatPercentage(tokens.ErrorRate, 1)),
+		formatWithCommas(tokens.Refreshes),
+		fmt.Sprintf("%.2f ms", tokens.Latency.Avg),
+		fmt.Sprintf("%.2f ms", tokens.Latency.P50),
+		fmt.Sprintf("%.2f ms", tokens.Latency.P95),
+		fmt.Sprintf("%.2f ms", tokens.Latency.P99),
+		fmt.Sprintf("%.2f ms", tokens.Latency.Max),
+	}
+	for col, cell := range cells {
+		ln := 0
+		if col == len(cells)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(colWidths[col], 5, cell, "1", ln, "C", false, 0, "")
+	}
+	if tokens.LastError != "" {
+		pdf.Ln(1)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.MultiCell(180, 4, "Last error: "+tokens.LastError, "", "L", false)
+	}
+	pdf.Ln(4)
+}
+
+// renderTLSSessions lists the TLS versions and cipher suites the target
+// negotiated, by number of handshakes.
+func renderTLSSessions(pdf *gofpdf.Fpdf, sessions []TLSSession) {
+	if len(sessions) == 0 {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+25+float64(len(sessions))*5 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	var total int64
+	for _, session := range sessions {
+		total += session.Handshakes
+	}
+
+	renderSectionHeader(pdf, "TLS Sessions")
+	colWidths := []float64{30, 90, 30, 30}
+	renderTableHeader(pdf, colWidths, []string{"Version", "Cipher Suite", "Handshakes", "Share"})
+
+	pdf.SetFont("Arial", "", 8)
+	for _, session := range sessions {
+		pdf.CellFormat(colWidths[0], 5, session.Version, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[1], 5, session.CipherSuite, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(colWidths[2], 5, formatWithCommas(session.Handshakes), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[3], 5, formatPercentage(float64(session.Handshakes)/float64(total)*100, 1), "1", 1, "C", false, 0, "")
+	}
+	pdf.Ln(4)
+}
+
+// renderGraphQLOperations lists the requests of each GraphQL operation,
+// counting the responses that carried GraphQL errors separately.
+func renderGraphQLOperations(pdf *gofpdf.Fpdf, operations []GraphQLMetrics) {
+	if len(operations) == 0 {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+20+float64(len(operations))*5 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	renderSectionHeader(pdf, "GraphQL Operations")
+	colWidths := []float64{40, 20, 24, 20, 19, 19, 19, 19}
+	renderTableHeader(pdf, colWidths, []string{"Operation", "Requests", "Errors", "GraphQL", "Average", "P50", "P95", "P99"})
+
+	pdf.SetFont("Arial", "", 8)
+	for _, op := range operations {
+		name := op.Operation
+		for pdf.GetStringWidth(name) > colWidths[0]-4 && len(name) > 3 {
+			name = name[:len(name)-4] + "..."
+		}
+		cells := []string{
+			name,
+			formatWithCommas(op.TotalRequests),
+			fmt.Sprintf("%s (%s)", formatWithCommas(op.ErrorCount), formatPercentage(op.ErrorRate, 1)),
+			formatWithCommas(op.GraphQLErrors),
+			fmt.Sprintf("%.2f ms", op.Latency.Avg),
+			fmt.Sprintf("%.2f ms", op.Latency.P50),
+			fmt.Sprintf("%.2f ms", op.Latency.P95),
+			fmt.Sprintf("%.2f ms", op.Latency.P99),
+		}
+		for col, cell := range cells {
+			ln, align := 0, "C"
+			if col == 0 {
+				align = "L"
+			}
+			if col == len(cells)-1 {
+				ln = 1
+			}
+			pdf.CellFormat(colWidths[col], 5, cell, "1", ln, align, false, 0, "")
+		}
+	}
+	pdf.Ln(4)
+}
+
+// renderWebSocketMetrics summarises the connections of a WebSocket test and
+// the messages sent and received on them.
+func renderWebSocketMetrics(pdf *gofpdf.Fpdf, ws *WebSocketMetrics) {
+	if ws == nil {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+35 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	renderSectionHeader(pdf, "WebSocket")
+	colWidths := []float64{24, 24, 22, 22, 22, 22, 22, 22}
+	renderTableHeader(pdf, colWidths, []string{"Connections", "Failed", "Disconnects", "Sent", "Received", "Unmatched", "Timeouts", "Connect P95"})
+
+	pdf.SetFont("Arial", "", 8)
+	cells := []string{
+		formatWithCommas(ws.Connections),
+		formatWithCommas(ws.ConnectFailures),
+		formatWithCommas(ws.Disconnects),
+		formatWithCommas(ws.MessagesSent),
+		formatWithCommas(ws.MessagesReceived),
+		formatWithCommas(ws.Unmatched),
+		formatWithCommas(ws.ReplyTimeouts),
+		fmt.Sprintf("%.2f ms", ws.ConnectLatency.P95),
+	}
+	for col, cell := range cells {
+		ln := 0
+		if col == len(cells)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(colWidths[col], 5, cell, "1", ln, "C", false, 0, "")
+	}
+	pdf.Ln(4)
+}
+
+// renderGRPCMetrics lists the calls of a gRPC test by gRPC status code, which
+// takes the place of the HTTP status.
+func renderGRPCMetrics(pdf *gofpdf.Fpdf, summary *GRPCMetrics) {
+	if summary == nil || summary.Calls == 0 {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+40 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	renderSectionHeader(pdf, "gRPC Status Codes")
+	if summary.StreamMessages > 0 {
+		pdf.SetFont("Arial", "", 9)
+		pdf.SetTextColor(colorMuted.R, colorMuted.G, colorMuted.B)
+		pdf.MultiCell(0, 5, fmt.Sprintf("Stream messages received: %s (%s per call)",
+			formatWithCommas(summary.StreamMessages), formatFloat(float64(summary.StreamMessages)/float64(summary.Calls), 2)), "", "L", false)
+		pdf.SetTextColor(colorText.R, colorText.G, colorText.B)
+		pdf.Ln(2)
+	}
+
+	colWidths := []float64{60, 60, 60}
+	renderTableHeader(pdf, colWidths, []string{"Status Code", "Calls", "Share"})
+	pdf.SetFont("Arial", "", 8)
+	for _, name := range sortedGRPCCodes(summary.StatusCodes) {
+		count := summary.StatusCodes[name]
+		pdf.CellFormat(colWidths[0], 5, name, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[1], 5, formatWithCommas(count), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[2], 5, formatPercentage(calculatePercentage(count, summary.Calls), 1), "1", 1, "C", false, 0, "")
+	}
+	pdf.Ln(4)
+}
+
+// renderStreamMetrics summarises the streams of a stream test and the timing
+// of their events.
+func renderStreamMetrics(pdf *gofpdf.Fpdf, summary *StreamMetrics) {
+	if summary == nil || summary.Streams == 0 {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+45 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	renderSectionHeader(pdf, "Streams")
+	pdf.SetFont("Arial", "", 9)
+	pdf.SetTextColor(colorMuted.R, colorMuted.G, colorMuted.B)
+	pdf.MultiCell(0, 5, fmt.Sprintf("%s streams, %s completed, %s aborted. Events per stream: %s average, %s min, %s max (%s in total)",
+		formatWithCommas(summary.Streams), formatWithCommas(summary.Completed), formatWithCommas(summary.Aborted),
+		formatFloat(summary.AvgEvents, 1), formatWithCommas(summary.MinEvents), formatWithCommas(summary.MaxEvents),
+		formatWithCommas(summary.Events)), "", "L", false)
+	pdf.SetTextColor(colorText.R, colorText.G, colorText.B)
+	pdf.Ln(2)
+
+	colWidths := []float64{36, 24, 24, 24, 24, 24, 24}
+	renderTableHeader(pdf, colWidths, []string{"Timing", "Count", "Average", "P50", "P95", "P99", "Max"})
+	pdf.SetFont("Arial", "", 8)
+	timings := []struct {
+		name  string
+		stats LatencyStats
+	}{
+		{"First byte", summary.FirstByte},
+		{"First event", summary.FirstEvent},
+		{"Event gap", summary.EventGap},
+		{"Stream duration", summary.Duration},
+	}
+	for _, timing := range timings {
+		cells := []string{
+			timing.name,
+			formatWithCommas(timing.stats.Count),
+			fmt.Sprintf("%.2f ms", timing.stats.Avg),
+			fmt.Sprintf("%.2f ms", timing.stats.P50),
+			fmt.Sprintf("%.2f ms", timing.stats.P95),
+			fmt.Sprintf("%.2f ms", timing.stats.P99),
+			fmt.Sprintf("%.2f ms", timing.stats.Max),
+		}
+		for col, cell := range cells {
+			ln, align := 0, "C"
+			if col == 0 {
+				align = "L"
+			}
+			if col == len(cells)-1 {
+				ln = 1
+			}
+			pdf.CellFormat(colWidths[col], 5, cell, "1", ln, align, false, 0, "")
+		}
+	}
+	pdf.Ln(4)
+}
+
+// renderSocketMetrics summarises the connections of a socket test, the bytes
+// sent and received on them and the connect and round-trip latency.
+func renderSocketMetrics(pdf *gofpdf.Fpdf, summary *SocketMetrics) {
+	if summary == nil {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+35 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	renderSectionHeader(pdf, "Socket")
+	colWidths := []float64{22, 20, 22, 24, 24, 22, 23, 23}
+	renderTableHeader(pdf, colWidths, []string{"Connections", "Failed", "Disconnects", "Bytes Sent", "Bytes Received", "Connect P95", "RTT P50", "RTT P95"})
+
+	pdf.SetFont("Arial", "", 8)
+	cells := []string{
+		formatWithCommas(summary.Connections),
+		formatWithCommas(summary.ConnectFailures),
+		formatWithCommas(summary.Disconnects),
+		formatWithCommas(summary.BytesSent),
+		formatWithCommas(summary.BytesReceived),
+		fmt.Sprintf("%.2f ms", summary.ConnectLatency.P95),
+		fmt.Sprintf("%.2f ms", summary.RoundTrip.P50),
+		fmt.Sprintf("%.2f ms", summary.RoundTrip.P95),
+	}
+	for col, cell := range cells {
+		ln := 0
+		if col == len(cells)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(colWidths[col], 5, cell, "1", ln, "C", false, 0, "")
+	}
+	pdf.Ln(4)
+}
+
+// renderStatusCodes lists every request by status code and status class.
+func renderStatusCodes(pdf *gofpdf.Fpdf, statusCodes map[int]int64) {
+	if len(statusCodes) == 0 {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+40 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	renderSectionHeader(pdf, "Status Codes")
+
+	var total int64
+	codes := make([]int, 0, len(statusCodes))
+	for code, count := range statusCodes {
+		codes = append(codes, code)
+		total += count
+	}
+	sort.Ints(codes)
+
+	classes := statusClasses(statusCodes)
+	pdf.SetFont("Arial", "", 9)
+	pdf.SetTextColor(colorMuted.R, colorMuted.G, colorMuted.B)
+	pdf.MultiCell(0, 5, fmt.Sprintf("2xx: %s   3xx: %s   4xx: %s   5xx: %s   No response: %s",
+		formatWithCommas(classes.Status2xx), formatWithCommas(classes.Status3xx),
+		formatWithCommas(classes.Status4xx), formatWithCommas(classes.Status5xx),
+		formatWithCommas(classes.NoResponse)), "", "L", false)
+	pdf.SetTextColor(colorText.R, colorText.G, colorText.B)
+	pdf.Ln(2)
+
+	colWidths := []float64{60, 60, 60}
+	renderTableHeader(pdf, colWidths, []string{"Status Code", "Requests", "Share"})
+	pdf.SetFont("Arial", "", 8)
+	for _, code := range codes {
+		label := strconv.Itoa(code)
+		if code == 0 {
+			label = "No response"
+		} else if text := http.StatusText(code); text != "" {
+			label += " " + text
+		}
+		count := statusCodes[code]
+		pdf.CellFormat(colWidths[0], 5, label, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[1], 5, formatWithCommas(count), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[2], 5, formatPercentage(calculatePercentage(count, total), 1), "1", 1, "C", false, 0, "")
+	}
+	pdf.Ln(4)
+}
+
+// renderErrorBreakdown lists failed requests by error category, with a sample
+// message for each, and by status code.
+func renderErrorBreakdown(pdf *gofpdf.Fpdf, breakdown ErrorBreakdown) {
+	if len(breakdown.Categories) == 0 {
+		return
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+50 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	renderSectionHeader(pdf, "Error Breakdown")
+
+	var totalErrors int64
+	categories := make([]string, 0, len(breakdown.Categories))
+	for category, stats := range breakdown.Categories {
+		categories = append(categories, category)
+		totalErrors += stats.Count
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		ci, cj := breakdown.Categories[categories[i]], breakdown.Categories[categories[j]]
+		if ci.Count != cj.Count {
+			return ci.Count > cj.Count
+		}
+		return categories[i] < categories[j]
+	})
+
+	colWidths := []float64{40, 22, 22, 96}
+	headers := []string{"Category", "Errors", "Share", "Sample Message"}
+	renderTableHeader(pdf, colWidths, headers)
+
+	pdf.SetFont("Arial", "", 8)
+	for _, category := range categories {
+		stats := breakdown.Categories[category]
+		sample := stats.Sample
+		if sample == "" {
+			sample = "-"
+		}
+		// Keep each sample on one row
+		for pdf.GetStringWidth(sample) > colWidths[3]-4 && len(sample) > 3 {
+			sample = sample[:len(sample)-4] + "..."
+		}
+		pdf.CellFormat(colWidths[0], 5, category, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[1], 5, formatWithCommas(stats.Count), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[2], 5, formatPercentage(calculatePercentage(stats.Count, totalErrors), 1), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[3], 5, sample, "1", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	codes := make([]int, 0, len(breakdown.StatusCodes))
+	for code := range breakdown.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	colWidths = []float64{60, 60, 60}
+	renderTableHeader(pdf, colWidths, []string{"Status Code", "Errors", "Share"})
+	pdf.SetFont("Arial", "", 8)
+	for _, code := range codes {
+		label := strconv.Itoa(code)
+		if code == 0 {
+			label = "No response"
+		}
+		count := breakdown.StatusCodes[code]
+		pdf.CellFormat(colWidths[0], 5, label, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[1], 5, formatWithCommas(count), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(colWidths[2], 5, formatPercentage(calculatePercentage(count, totalErrors), 1), "1", 1, "C", false, 0, "")
+	}
+	pdf.Ln(4)
+}
+
+// renderTableHeader draws a shaded header row and leaves the cursor at the
+// start of the first data row.
+func renderTableHeader(pdf *gofpdf.Fpdf, colWidths []float64, headers []string) {
+	pdf.SetFont("Arial", "B", 9)
+	pdf.SetFillColor(colorSectionFill.R, colorSectionFill.G, colorSectionFill.B)
+	for idx, header := range headers {
+		ln := 0
+		if idx == len(headers)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(colWidths[idx], 6, header, "1", ln, "C", true, 0, "")
+	}
+}
+
 func renderTimeSeriesInsights(pdf *gofpdf.Fpdf, summary timeSeriesSummary) {
 	renderSectionHeader(pdf, "Time Series Insights")
 
@@ -513,6 +1333,177 @@ func formatDurationHuman(d time.Duration) string {
 	return strings.Join(parts, " ")
 }
 
+// formatTransport summarises the transport settings that shape connection
+// behaviour.
+func formatTransport(cfg TransportConfig) string {
+	parts := make([]string, 0, 4)
+	switch cfg.HTTP2 {
+	case HTTP2Off:
+		parts = append(parts, "HTTP/1.1 only")
+	case HTTP2H2C:
+		parts = append(parts, "HTTP/2 cleartext (h2c)")
+	default:
+		parts = append(parts, "HTTP/2 when negotiated")
+	}
+	if cfg.DisableKeepAlive {
+		parts = append(parts, "keep-alive off")
+	} else if cfg.HTTP2 != HTTP2H2C {
+		parts = append(parts, fmt.Sprintf("%d idle conns/host", cfg.MaxIdleConnsPerHost))
+	}
+	parts = append(parts, fmt.Sprintf("%s request timeout", formatDurationHuman(cfg.RequestTimeout())))
+	if cfg.InsecureSkipVerify {
+		parts = append(parts, "TLS verification off")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatTestData summarises a test's data file. The checksum prefix is enough
+// to tell whether two reports used the same data.
+func formatTestData(data *DataSummary) string {
+	return fmt.Sprintf("%s, %s rows x %d columns, %s, sha256 %.12s",
+		strings.ToUpper(data.Format), formatWithCommas(int64(data.Rows)), len(data.Columns), data.Mode, data.Checksum)
+}
+
+// formatThinkTime describes a think time distribution.
+func formatThinkTime(t *ThinkTime) string {
+	var desc string
+	switch t.Type {
+	case ThinkTimeConstant:
+		return fmt.Sprintf("Constant %d ms", t.DurationMs)
+	case ThinkTimeUniform:
+		return fmt.Sprintf("Uniform %d-%d ms", t.MinMs, t.MaxMs)
+	case ThinkTimeNormal:
+		desc = fmt.Sprintf("Normal, mean %d ms, std dev %d ms", t.MeanMs, t.StdDevMs)
+	case ThinkTimeExponential:
+		desc = fmt.Sprintf("Exponential, mean %d ms", t.MeanMs)
+	default:
+		return t.Type
+	}
+	if t.MaxMs > 0 {
+		desc += fmt.Sprintf(", within %d-%d ms", t.MinMs, t.MaxMs)
+	} else if t.MinMs > 0 {
+		desc += fmt.Sprintf(", at least %d ms", t.MinMs)
+	}
+	return desc
+}
+
+// formatRedirects describes a redirect policy that differs from the default
+// of following up to 10 hops as one request.
+func formatRedirects(cfg RedirectConfig) string {
+	switch {
+	case cfg.Policy == RedirectNone:
+		return "Not followed"
+	case cfg.SeparateSamples:
+		return fmt.Sprintf("Followed up to %d hops, each recorded as a request", cfg.maxHops())
+	case cfg.maxHops() != DefaultMaxRedirects:
+		return fmt.Sprintf("Followed up to %d hops", cfg.maxHops())
+	}
+	return ""
+}
+
+// formatClientTLS describes a test's client certificate and CA bundle.
+func formatClientTLS(summary *TLSSummary) string {
+	var parts []string
+	if summary.ClientCert {
+		subject := summary.Subject
+		if len(subject) > 60 {
+			subject = subject[:57] + "..."
+		}
+		cert := "Client certificate " + subject
+		if summary.NotAfter != nil {
+			cert += ", expires " + summary.NotAfter.Format("2006-01-02")
+		}
+		parts = append(parts, cert)
+	}
+	if summary.CACerts > 0 {
+		unit := "certificates"
+		if summary.CACerts == 1 {
+			unit = "certificate"
+		}
+		parts = append(parts, fmt.Sprintf("custom CA bundle of %d %s", summary.CACerts, unit))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// formatWebSocket describes the messages a WebSocket test sends.
+func formatWebSocket(cfg *WebSocketConfig) string {
+	if len(cfg.Messages) == 0 {
+		return "Connections only, no scripted messages"
+	}
+	matching := "replies matched in order"
+	if cfg.CorrelationPath != "" {
+		matching = "replies matched by " + cfg.CorrelationPath
+	}
+	return fmt.Sprintf("%d scripted messages at %s per second per connection, %s",
+		len(cfg.Messages), formatFloat(cfg.MessageRate, 2), matching)
+}
+
+// formatGRPC describes the method a gRPC test calls, where its message types
+// came from and how many connections it used.
+func formatGRPC(cfg *GRPCConfig) string {
+	kind := "unary"
+	if cfg.ServerStreaming {
+		kind = "server streaming"
+	}
+	schema := "server reflection"
+	if cfg.Schema == GRPCSchemaDescriptorSet {
+		schema = "uploaded descriptor set"
+	}
+	connections := "1 connection"
+	if cfg.Connections > 1 {
+		connections = fmt.Sprintf("%d connections", cfg.Connections)
+	}
+	return fmt.Sprintf("%s (%s), types from %s, %s", cfg.Method, kind, schema, connections)
+}
+
+// formatGraphQL describes the GraphQL operation of a single-request test.
+func formatGraphQL(cfg *GraphQLRequest) string {
+	operation := graphQLOperationName(cfg)
+	var variables map[string]json.RawMessage
+	if json.Unmarshal(cfg.Variables, &variables) != nil || len(variables) == 0 {
+		return operation + ", no variables"
+	}
+	return fmt.Sprintf("%s, %d variables", operation, len(variables))
+}
+
+// formatStream describes the format and limits of a stream test.
+func formatStream(cfg *StreamConfig) string {
+	format := "Server-sent events"
+	if cfg.Format == StreamFormatLines {
+		format = "Line-delimited events"
+	}
+	limits := fmt.Sprintf("closed after %s ms", formatWithCommas(int64(cfg.MaxDurationMs)))
+	if cfg.MaxEvents > 0 {
+		limits += fmt.Sprintf(" or %s events", formatWithCommas(int64(cfg.MaxEvents)))
+	}
+	if cfg.IdleTimeoutMs > 0 {
+		limits += fmt.Sprintf(", idle timeout %s ms", formatWithCommas(int64(cfg.IdleTimeoutMs)))
+	}
+	return format + ", " + limits
+}
+
+// formatSocket describes what a socket test writes and how it reads the
+// response.
+func formatSocket(cfg *SocketConfig) string {
+	response := "reply is the first read"
+	switch {
+	case cfg.NoReply:
+		response = "no reply read"
+	case cfg.Delimiter != "":
+		response = fmt.Sprintf("reply ends with %q", cfg.Delimiter)
+		if cfg.Encoding == SocketEncodingHex {
+			response = "reply ends with hex " + cfg.Delimiter
+		}
+	case cfg.ResponseBytes > 0:
+		response = fmt.Sprintf("reply of %d bytes", cfg.ResponseBytes)
+	}
+	connection := "one connection per user"
+	if cfg.NewConnection {
+		connection = "new connection per request"
+	}
+	return fmt.Sprintf("%s, %s payload, %s, %s", strings.ToUpper(cfg.Protocol), cfg.Encoding, response, connection)
+}
+
 func formatLatencyValue(value float64) string {
 	if value <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
 		return "—"
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// PhaseTimings breaks a request down into its network and server phases, in
+// milliseconds. DNS, Connect and TLS are zero when a pooled connection was
+// reused.
+type PhaseTimings struct {
+	DNS      float64 `json:"dns"`
+	Connect  float64 `json:"connect"`
+	TLS      float64 `json:"tls"`
+	TTFB     float64 `json:"ttfb"`     // From having a connection to the first response byte
+	Download float64 `json:"download"` // From the first response byte to the end of the body
+}
+
+// Total is the sum of all phases.
+func (p PhaseTimings) Total() float64 {
+	return p.DNS + p.Connect + p.TLS + p.TTFB + p.Download
+}
+
+func (p PhaseTimings) add(other PhaseTimings) PhaseTimings {
+	return PhaseTimings{
+		DNS:      p.DNS + other.DNS,
+		Connect:  p.Connect + other.Connect,
+		TLS:      p.TLS + other.TLS,
+		TTFB:     p.TTFB + other.TTFB,
+		Download: p.Download + other.Download,
+	}
+}
+
+// average divides accumulated timings by the number of requests they cover.
+func (p PhaseTimings) average(count int64) PhaseTimings {
+	if count == 0 {
+		return PhaseTimings{}
+	}
+	n := float64(count)
+	return PhaseTimings{
+		DNS:      p.DNS / n,
+		Connect:  p.Connect / n,
+		TLS:      p.TLS / n,
+		TTFB:     p.TTFB / n,
+		Download: p.Download / n,
+	}
+}
+
+// phaseTrace collects httptrace timestamps for one request. Callbacks can
+// fire from the transport's dialing goroutines, so access is locked.
+type phaseTrace struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	firstByte    time.Time
+	tlsState     *tls.ConnectionState // Set when this request completed a TLS handshake
+}
+
+func (pt *phaseTrace) clientTrace() *httptrace.ClientTrace {
+	mark := func(field *time.Time) {
+		pt.mu.Lock()
+		*field = time.Now()
+		pt.mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { mark(&pt.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { mark(&pt.dnsDone) },
+		ConnectStart: func(string, string) {
+			// Dual-stack dialing may start several attempts; time from the first
+			pt.mu.Lock()
+			if pt.connectStart.IsZero() {
+				pt.connectStart = time.Now()
+			}
+			pt.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				mark(&pt.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() { mark(&pt.tlsStart) },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			pt.mu.Lock()
+			pt.tlsDone = time.Now()
+			if err == nil {
+				pt.tlsState = &state
+			}
+			pt.mu.Unlock()
+		},
+		GotConn:              func(httptrace.GotConnInfo) { mark(&pt.gotConn) },
+		GotFirstResponseByte: func() { mark(&pt.firstByte) },
+	}
+}
+
+// timings converts the collected timestamps into phases. bodyDone is when
+// the response body was fully read.
+func (pt *phaseTrace) timings(bodyDone time.Time) PhaseTimings {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	return PhaseTimings{
+		DNS:      phaseMs(pt.dnsStart, pt.dnsDone),
+		Connect:  phaseMs(pt.connectStart, pt.connectDone),
+		TLS:      phaseMs(pt.tlsStart, pt.tlsDone),
+		TTFB:     phaseMs(pt.gotConn, pt.firstByte),
+		Download: phaseMs(pt.firstByte, bodyDone),
+	}
+}
+
+// tlsSession returns the version and cipher suite of the TLS handshake made
+// for this request, if it made one.
+func (pt *phaseTrace) tlsSession() (version, cipherSuite uint16, ok bool) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if pt.tlsState == nil {
+		return 0, 0, false
+	}
+	return pt.tlsState.Version, pt.tlsState.CipherSuite, true
+}
+
+// phaseMs returns the milliseconds between two trace events, or 0 if either
+// did not happen.
+func phaseMs(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start).Seconds() * 1000
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// MaxStages caps how many stages a single load profile may contain.
+const MaxStages = 20
+
+// Stage is one segment of a load profile. Over Duration seconds the load moves
+// linearly from the previous stage's target (0 for the first stage) to Target,
+// which is a number of users for the closed loop and requests per second for
+// the arrival-rate executor. A zero-duration stage jumps straight to Target.
+type Stage struct {
+	Duration int     `json:"duration"`
+	Target   float64 `json:"target"`
+}
+
+// LoadProfile returns the stages that drive the test. Tests started without
+// explicit stages get the classic profile: a linear ramp over RampUpSec
+// followed by a hold at full load until Duration.
+func (tr *TestRun) LoadProfile() []Stage {
+	if len(tr.Stages) > 0 {
+		return tr.Stages
+	}
+
+	target := float64(tr.TotalUsers)
+	if tr.Executor == ExecutorArrivalRate {
+		target = tr.TargetRPS
+	}
+	return []Stage{
+		{Duration: tr.RampUpSec, Target: target},
+		{Duration: tr.Duration - tr.RampUpSec, Target: target},
+	}
+}
+
+// validateStages checks a requested profile against the executor's limits and
+// returns its total duration and peak target.
+func validateStages(stages []Stage, executor string) (int, float64, error) {
+	if len(stages) > MaxStages {
+		return 0, 0, fmt.Errorf("at most %d stages are allowed", MaxStages)
+	}
+
+	maxTarget := float64(MaxUsers)
+	if executor == ExecutorArrivalRate {
+		maxTarget = MaxTargetRPS
+	}
+
+	totalDuration := 0
+	peak := 0.0
+	for i, stage := range stages {
+		if stage.Duration < 0 {
+			return 0, 0, fmt.Errorf("stage %d: duration cannot be negative", i+1)
+		}
+		if stage.Target < 0 || stage.Target > maxTarget {
+			return 0, 0, fmt.Errorf("stage %d: target must be between 0 and %g", i+1, maxTarget)
+		}
+		totalDuration += stage.Duration
+		peak = math.Max(peak, stage.Target)
+	}
+
+	if totalDuration < MinDuration || totalDuration > MaxDuration {
+		return 0, 0, fmt.Errorf("total stage duration must be between %d and %d seconds", MinDuration, MaxDuration)
+	}
+	if peak <= 0 {
+		return 0, 0, fmt.Errorf("at least one stage must have a target above 0")
+	}
+
+	return totalDuration, peak, nil
+}
+
+// profileTarget returns the load the profile asks for at the given point of
+// the test, interpolating linearly within the current stage.
+func profileTarget(stages []Stage, elapsed time.Duration) float64 {
+	previous := 0.0
+	var stageStart time.Duration
+	for _, stage := range stages {
+		stageDuration := time.Duration(stage.Duration) * time.Second
+		if elapsed < stageStart+stageDuration {
+			progress := float64(elapsed-stageStart) / float64(stageDuration)
+			return previous + (stage.Target-previous)*progress
+		}
+		previous = stage.Target
+		stageStart += stageDuration
+	}
+	return previous
+}
+
+// runClosedLoop keeps the number of running users in line with the load
+// profile, starting users while the target rises and retiring the most
+// recently started ones when a stage lowers it. A retired user finishes its
+// in-flight request before exiting.
+func (tm *TestManager) runClosedLoop(testCtx *TestContext, wg *sync.WaitGroup, stopChan <-chan struct{}) {
+	defer wg.Done()
+
+	ctx := testCtx.Context
+	profile := testCtx.TestRun.LoadProfile()
+
+	ticker := time.NewTicker(100 * time.Millisecond) // Check every 100ms
+	defer ticker.Stop()
+
+	var retireChans []chan struct{}
+	start := time.Now()
+
+	for {
+		target := profileTarget(profile, time.Since(start))
+		testCtx.Metrics.SetTargetLoad(target)
+		targetUsers := int(math.Round(target))
+
+		for len(retireChans) < targetUsers {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopChan:
+				return
+			default:
+			}
+			retire := make(chan struct{})
+			retireChans = append(retireChans, retire)
+			wg.Add(1)
+			go tm.runUser(testCtx, wg, stopChan, retire)
+		}
+		for len(retireChans) > targetUsers {
+			last := len(retireChans) - 1
+			close(retireChans[last])
+			retireChans = retireChans[:last]
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	MaxScenarioSteps  = 20    // Maximum steps in a scenario
+	MaxStepNameLength = 64    // Maximum length of a step name
+	MaxThinkTimeMs    = 60000 // Maximum pause after a step
+)
+
+var validMethods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"}
+
+// ScenarioStep is one request of a scenario. A user runs the steps in order
+// on every iteration, pausing for the step's think time or pacing after each
+// one. The URL path and query, header values and body are templates that can
+// use built-in functions and variables set by earlier extractors.
+type ScenarioStep struct {
+	Name        string            `json:"name"`
+	URL         string            `json:"url"`
+	Method      string            `json:"method,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"` // Added to the test's headers, overriding them
+	Body        string            `json:"body,omitempty"`
+	ThinkTimeMs int               `json:"think_time_ms,omitempty"` // Constant think time; shorthand for think_time
+	ThinkTime   *ThinkTime        `json:"think_time,omitempty"`    // Pause after the step, overriding the test's
+	PacingMs    int               `json:"pacing_ms,omitempty"`     // Time from this step's request to the next step, instead of think time
+	Weight      int               `json:"weight,omitempty"`        // Relative share of iterations in a request mix
+	Extract     []Extractor       `json:"extract,omitempty"`       // Variables saved from the response
+	Assertions  []Assertion       `json:"assertions,omitempty"`    // Checks a response must pass to count as a success
+	GraphQL     *GraphQLRequest   `json:"graphql,omitempty"`       // Sent as the body instead of Body
+
+	url, body *requestTemplate
+	headers   map[string]*requestTemplate
+	graphql   *graphQLTemplate
+}
+
+// StepMetrics summarises the requests of one scenario step or mix request.
+type StepMetrics struct {
+	Name          string       `json:"name"`
+	TotalRequests int64        `json:"total_requests"`
+	SuccessCount  int64        `json:"success_count"`
+	ErrorCount    int64        `json:"error_count"`
+	ErrorRate     float64      `json:"error_rate"`
+	Share         float64      `json:"share"` // Percentage of all requests
+	Latency       LatencyStats `json:"latency"`
+}
+
+// stepCollector accumulates the requests of one step while a test runs.
+type stepCollector struct {
+	order        int // Position in which the step was first recorded
+	latencies    *hdrhistogram.Histogram
+	successCount int64
+	errorCount   int64
+}
+
+// validateMethod upper-cases an HTTP method, defaulting to GET, and checks it
+// is allowed.
+func validateMethod(method string) (string, error) {
+	if method == "" {
+		return "GET", nil
+	}
+	method = strings.ToUpper(method)
+	for _, m := range validMethods {
+		if method == m {
+			return method, nil
+		}
+	}
+	return "", fmt.Errorf("invalid HTTP method. Allowed: %v", validMethods)
+}
+
+// validateScenario checks a scenario and normalizes its steps in place. Step
+// URLs starting with "/" are resolved against baseURL.
+func validateScenario(steps []ScenarioStep, baseURL string) error {
+	if len(steps) > MaxScenarioSteps {
+		return fmt.Errorf("at most %d steps are allowed", MaxScenarioSteps)
+	}
+
+	var base *url.URL
+	if baseURL != "" {
+		parsed, err := url.Parse(baseURL)
+		if err != nil {
+			return fmt.Errorf("invalid host: %v", err)
+		}
+		base = parsed
+	}
+
+	names := make(map[string]bool, len(steps))
+	for i := range steps {
+		step := &steps[i]
+
+		step.Name = strings.TrimSpace(step.Name)
+		if step.Name == "" {
+			return fmt.Errorf("step %d: name is required", i+1)
+		}
+		if len(step.Name) > MaxStepNameLength {
+			return fmt.Errorf("step %d: name must be at most %d characters", i+1, MaxStepNameLength)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("step %d: duplicate name %q", i+1, step.Name)
+		}
+		names[step.Name] = true
+
+		// Relative URLs are joined to the base origin as written, keeping any
+		// template expressions unescaped
+		step.URL = strings.TrimSpace(step.URL)
+		if strings.HasPrefix(step.URL, "//") {
+			return fmt.Errorf("step %q: protocol-relative urls are not allowed", step.Name)
+		}
+		if strings.HasPrefix(step.URL, "/") {
+			if base == nil {
+				return fmt.Errorf("step %q: a relative url requires host", step.Name)
+			}
+			step.URL = base.Scheme + "://" + base.Host + step.URL
+		}
+		if err := validateHost(step.URL); err != nil {
+			return fmt.Errorf("step %q: %v", step.Name, err)
+		}
+		step.URL = normalizeHost(step.URL)
+
+		if step.GraphQL != nil && step.Method == "" {
+			step.Method = "POST"
+		}
+		method, err := validateMethod(step.Method)
+		if err != nil {
+			return fmt.Errorf("step %q: %v", step.Name, err)
+		}
+		step.Method = method
+		if step.GraphQL != nil && (step.Method != "POST" || step.Body != "") {
+			return fmt.Errorf("step %q: a graphql request is sent as a POST body, without method or body", step.Name)
+		}
+		if step.Body != "" && (step.Method == "GET" || step.Method == "HEAD") {
+			return fmt.Errorf("step %q: request body not allowed for GET or HEAD methods", step.Name)
+		}
+
+		if step.ThinkTimeMs < 0 || step.ThinkTimeMs > MaxThinkTimeMs {
+			return fmt.Errorf("step %q: think_time_ms must be between 0 and %d", step.Name, MaxThinkTimeMs)
+		}
+		if step.PacingMs < 0 || step.PacingMs > MaxPacingMs {
+			return fmt.Errorf("step %q: pacing_ms must be between 0 and %d", step.Name, MaxPacingMs)
+		}
+		pauses := 0
+		for _, set := range []bool{step.ThinkTimeMs > 0, step.ThinkTime != nil, step.PacingMs > 0} {
+			if set {
+				pauses++
+			}
+		}
+		if pauses > 1 {
+			return fmt.Errorf("step %q: only one of think_time_ms, think_time and pacing_ms can be set", step.Name)
+		}
+		if step.ThinkTime != nil {
+			if err := validateThinkTime(step.ThinkTime); err != nil {
+				return fmt.Errorf("step %q: think_time: %v", step.Name, err)
+			}
+		}
+
+		if err := validateExtractors(step.Extract); err != nil {
+			return fmt.Errorf("step %q: %v", step.Name, err)
+		}
+		if err := validateAssertions(step.Assertions); err != nil {
+			return fmt.Errorf("step %q: %v", step.Name, err)
+		}
+		if err := compileStepTemplates(step); err != nil {
+			return fmt.Errorf("step %q: %v", step.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// scenarioURLs returns the target URL of every step.
+func scenarioURLs(steps []ScenarioStep) []string {
+	urls := make([]string, len(steps))
+	for i, step := range steps {
+		urls[i] = step.URL
+	}
+	return urls
+}
+
+// runIteration sends a scenario's steps in order, or one request picked by
+// weight for a request mix. The first step is measured from intendedStart,
+// later steps from when the previous step and its pause finished. It returns
+// the time spent pausing, and false if the user was stopped or
+// retired before the iteration completed or the test data ran out.
+func (tm *TestManager) runIteration(ctx context.Context, client *http.Client, testCtx *TestContext, user *virtualUser, intendedStart time.Time, stopChan, retire <-chan struct{}) (time.Duration, bool) {
+	user.iteration++
+	if testCtx.Data != nil && !testCtx.Data.draw(user) {
+		return 0, false
+	}
+	steps := testCtx.Steps
+	if testCtx.Mix != nil {
+		picked := testCtx.Mix.pick()
+		steps = steps[picked : picked+1]
+	}
+
+	var thought time.Duration
+	for i := range steps {
+		step := &steps[i]
+		if i > 0 {
+			intendedStart = time.Now()
+		}
+		sent := time.Now()
+		tm.executeRequest(ctx, client, testCtx, step, user, intendedStart)
+
+		pause := stepPause(step, testCtx.TestRun.ThinkTime, user, sent)
+		if pause <= 0 {
+			continue
+		}
+		timer := time.NewTimer(pause)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return thought, false
+		case <-stopChan:
+			timer.Stop()
+			return thought, false
+		case <-retire:
+			timer.Stop()
+			return thought, false
+		case <-timer.C:
+			thought += pause
+		}
+	}
+	return thought, true
+}
+
+// RegisterSteps adds the named steps in their configured order, so they are
+// reported in that order and even if they never receive a request.
+func (mc *MetricsCollector) RegisterSteps(steps []ScenarioStep) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	for _, step := range steps {
+		if step.Name != "" {
+			mc.stepLocked(step.Name)
+		}
+	}
+}
+
+// RecordStep adds a request to its step's metrics.
+func (mc *MetricsCollector) RecordStep(name string, latency float64, success bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	step := mc.stepLocked(name)
+	recordLatency(step.latencies, latency)
+	if success {
+		step.successCount++
+	} else {
+		step.errorCount++
+	}
+}
+
+// stepLocked returns the collector of a step, creating it if needed. mc.mu
+// must be held.
+func (mc *MetricsCollector) stepLocked(name string) *stepCollector {
+	step, ok := mc.steps[name]
+	if !ok {
+		step = &stepCollector{
+			order:     len(mc.steps),
+			latencies: newLatencyHistogram(mc.precision),
+		}
+		mc.steps[name] = step
+	}
+	return step
+}
+
+// StepMetrics summarises every step recorded so far, in the order the steps
+// were registered or first recorded.
+func (mc *MetricsCollector) StepMetrics() []StepMetrics {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	names := make([]string, 0, len(mc.steps))
+	for name := range mc.steps {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return mc.steps[names[i]].order < mc.steps[names[j]].order
+	})
+
+	var requests int64
+	for _, step := range mc.steps {
+		requests += step.successCount + step.errorCount
+	}
+
+	summaries := make([]StepMetrics, 0, len(names))
+	for _, name := range names {
+		step := mc.steps[name]
+		total := step.successCount + step.errorCount
+		summary := StepMetrics{
+			Name:          name,
+			TotalRequests: total,
+			SuccessCount:  step.successCount,
+			ErrorCount:    step.errorCount,
+			Latency:       histogramStats(step.latencies),
+		}
+		if total > 0 {
+			summary.ErrorRate = float64(step.errorCount) / float64(total) * 100
+			summary.Share = float64(total) / float64(requests) * 100
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Redirect policies.
+const (
+	RedirectFollow = "follow" // Follow redirects up to MaxHops (default)
+	RedirectNone   = "none"   // Record the redirect response itself
+)
+
+const (
+	DefaultMaxRedirects = 10 // Same limit as net/http
+	MaxRedirectHops     = 20
+	MaxSeedCookies      = 50
+)
+
+var (
+	errRedirectLimit   = errors.New("stopped after too many redirects")
+	errRedirectBlocked = errors.New("redirect target not allowed")
+)
+
+// CookieConfig gives every virtual user its own cookie jar, so cookies the
+// target sets are sent back on the user's later requests.
+type CookieConfig struct {
+	Enabled bool         `json:"enabled"`
+	Seed    []SeedCookie `json:"seed,omitempty"` // Cookies each jar starts with
+}
+
+// SeedCookie is placed in each user's jar before its first request. Without a
+// domain it is sent to the hosts of the test's requests only.
+type SeedCookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value,omitempty"` // Not stored with the test
+	Domain string `json:"domain,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// RedirectConfig controls how redirect responses are handled.
+type RedirectConfig struct {
+	Policy          string `json:"policy"`                     // "follow" or "none"
+	MaxHops         int    `json:"max_hops"`                   // Redirects followed per request
+	SeparateSamples bool   `json:"separate_samples,omitempty"` // Record every hop as a request of its own
+}
+
+// validateCookieConfig checks a test's cookie settings.
+func validateCookieConfig(cfg *CookieConfig) error {
+	if len(cfg.Seed) > 0 && !cfg.Enabled {
+		return fmt.Errorf("seed cookies require enabled")
+	}
+	if len(cfg.Seed) > MaxSeedCookies {
+		return fmt.Errorf("at most %d seed cookies are allowed", MaxSeedCookies)
+	}
+	for i, seed := range cfg.Seed {
+		cookie := seed.cookie()
+		if err := cookie.Valid(); err != nil {
+			return fmt.Errorf("seed cookie %d: %v", i+1, err)
+		}
+		if seed.Path != "" && !strings.HasPrefix(seed.Path, "/") {
+			return fmt.Errorf("seed cookie %q: path must start with /", seed.Name)
+		}
+	}
+	return nil
+}
+
+// redacted returns the settings without seed cookie values, which may be
+// session tokens.
+func (cfg *CookieConfig) redacted() *CookieConfig {
+	out := &CookieConfig{Enabled: cfg.Enabled}
+	for _, seed := range cfg.Seed {
+		seed.Value = ""
+		out.Seed = append(out.Seed, seed)
+	}
+	return out
+}
+
+func (seed SeedCookie) cookie() *http.Cookie {
+	path := seed.Path
+	if path == "" {
+		path = "/"
+	}
+	return &http.Cookie{Name: seed.Name, Value: seed.Value, Domain: seed.Domain, Path: path}
+}
+
+// validateRedirectConfig checks the redirect settings and fills in defaults.
+func validateRedirectConfig(cfg *RedirectConfig) error {
+	if cfg.Policy == "" {
+		cfg.Policy = RedirectFollow
+	}
+	if cfg.Policy != RedirectFollow && cfg.Policy != RedirectNone {
+		return fmt.Errorf("policy must be one of %v", []string{RedirectFollow, RedirectNone})
+	}
+	if cfg.MaxHops == 0 {
+		cfg.MaxHops = DefaultMaxRedirects
+	}
+	if cfg.MaxHops < 1 || cfg.MaxHops > MaxRedirectHops {
+		return fmt.Errorf("max_hops must be between 1 and %d", MaxRedirectHops)
+	}
+	if cfg.SeparateSamples && cfg.Policy != RedirectFollow {
+		return fmt.Errorf("separate_samples requires the %s policy", RedirectFollow)
+	}
+	return nil
+}
+
+// maxHops returns the redirect limit, defaulting for tests recorded before it
+// was configurable.
+func (cfg RedirectConfig) maxHops() int {
+	if cfg.MaxHops <= 0 {
+		return DefaultMaxRedirects
+	}
+	return cfg.MaxHops
+}
+
+// newHTTPClient builds the client of one virtual user. Users share the test's
+// transport; with cookies enabled each gets a jar of its own.
+func newHTTPClient(testCtx *TestContext) *http.Client {
+	redirects := testCtx.TestRun.Redirects
+	// The requests of a stream test are bounded by their streamWatch instead,
+	// as the timeout covers reading the whole body
+	timeout := testCtx.TestRun.Transport.RequestTimeout()
+	if testCtx.TestRun.Stream != nil {
+		timeout = 0
+	}
+	client := &http.Client{
+		Transport: testCtx.Transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Separately sampled hops are followed by executeRequest
+			if redirects.Policy == RedirectNone || redirects.SeparateSamples {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > redirects.maxHops() {
+				return errRedirectLimit
+			}
+			if err := validateRedirectTarget(req.URL); err != nil {
+				return err
+			}
+			signRequest(req, testCtx.AuthConfig, time.Now())
+			return nil
+		},
+	}
+	if testCtx.Cookies != nil && testCtx.Cookies.Enabled {
+		client.Jar = newSessionJar(testCtx)
+	}
+	return client
+}
+
+// validateRedirectTarget applies the SSRF checks of the test's own URLs to a
+// redirect location.
+func validateRedirectTarget(target *url.URL) error {
+	if err := validateHost(target.String()); err != nil {
+		return fmt.Errorf("%w: %v", errRedirectBlocked, err)
+	}
+	return nil
+}
+
+// sessionJar is a user's cookie jar. A session is established the first time
+// the target sets a cookie in it.
+type sessionJar struct {
+	http.CookieJar
+	established sync.Once
+	metrics     *MetricsCollector
+}
+
+func newSessionJar(testCtx *TestContext) *sessionJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	for _, seed := range testCtx.Cookies.Seed {
+		cookie := seed.cookie()
+		if seed.Domain != "" {
+			jar.SetCookies(&url.URL{Scheme: "https", Host: strings.TrimPrefix(seed.Domain, ".")}, []*http.Cookie{cookie})
+			continue
+		}
+		for _, step := range testCtx.Steps {
+			if target, err := url.Parse(step.URL); err == nil {
+				jar.SetCookies(target, []*http.Cookie{cookie})
+			}
+		}
+	}
+	return &sessionJar{CookieJar: jar, metrics: testCtx.Metrics}
+}
+
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.CookieJar.SetCookies(u, cookies)
+	if len(cookies) > 0 {
+		j.established.Do(func() {
+			atomic.AddInt64(&j.metrics.SessionsEstablished, 1)
+		})
+	}
+}
+
+// isRedirect reports whether a response redirects to another location.
+func isRedirect(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return resp.Header.Get("Location") != ""
+	}
+	return false
+}
+
+// redirectRequest builds the request following a redirect the way net/http
+// does: 301, 302 and 303 switch to GET without a body, 307 and 308 repeat the
+// method and body. Cookies are left to the client's jar on every hop, and
+// credentials are dropped when the redirect leaves the host.
+func redirectRequest(ctx context.Context, req *http.Request, resp *http.Response, body string) (*http.Request, error) {
+	location, err := resp.Location()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRedirectTarget(location); err != nil {
+		return nil, err
+	}
+
+	method, keepBody := req.Method, true
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+		keepBody = false
+		if method != http.MethodGet && method != http.MethodHead {
+			method = http.MethodGet
+		}
+	}
+	var bodyReader io.Reader
+	if keepBody && body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+
+	next, err := http.NewRequestWithContext(ctx, method, location.String(), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	next.Header = req.Header.Clone()
+	next.Header.Del("Cookie")
+	next.Header.Del("Cookie2")
+	if !keepBody {
+		next.Header.Del("Content-Type")
+	}
+	if location.Hostname() != req.URL.Hostname() {
+		for _, key := range []string{"Authorization", "Www-Authenticate"} {
+			next.Header.Del(key)
+		}
+	}
+	return next, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Request signing auth types. The signature is computed for every request
+// after templating, just before it is sent.
+const (
+	AuthHMAC     = "hmac"      // HMAC-SHA256 over method, path, timestamp and body
+	AuthAWSSigV4 = "aws_sigv4" // AWS Signature Version 4
+)
+
+// HMAC signature encodings.
+const (
+	SignatureHex    = "hex" // Default
+	SignatureBase64 = "base64"
+)
+
+const (
+	DefaultSignatureHeader = "X-Signature"
+	DefaultTimestampHeader = "X-Timestamp"
+	DefaultKeyIDHeader     = "X-Key-Id"
+	DefaultAWSService      = "execute-api" // API Gateway
+
+	awsSigningAlgorithm = "AWS4-HMAC-SHA256"
+	awsDateFormat       = "20060102T150405Z"
+)
+
+// isSigned reports whether an auth type signs every request.
+func isSigned(authType string) bool {
+	return authType == AuthHMAC || authType == AuthAWSSigV4
+}
+
+// validateSigningConfig checks the signing settings of a test's auth and
+// fills in defaults.
+func validateSigningConfig(cfg *AuthConfig) error {
+	switch cfg.Type {
+	case AuthHMAC:
+		if cfg.HMACSecret == "" {
+			return fmt.Errorf("hmac_secret is required")
+		}
+		if cfg.SignatureEncoding == "" {
+			cfg.SignatureEncoding = SignatureHex
+		}
+		if cfg.SignatureEncoding != SignatureHex && cfg.SignatureEncoding != SignatureBase64 {
+			return fmt.Errorf("signature_encoding must be one of %v", []string{SignatureHex, SignatureBase64})
+		}
+		if cfg.SignatureHeader == "" {
+			cfg.SignatureHeader = DefaultSignatureHeader
+		}
+		if cfg.TimestampHeader == "" {
+			cfg.TimestampHeader = DefaultTimestampHeader
+		}
+		if cfg.KeyIDHeader == "" {
+			cfg.KeyIDHeader = DefaultKeyIDHeader
+		}
+		for _, name := range []string{cfg.SignatureHeader, cfg.TimestampHeader, cfg.KeyIDHeader} {
+			if !httpguts.ValidHeaderFieldName(name) {
+				return fmt.Errorf("invalid header name %q", name)
+			}
+		}
+	case AuthAWSSigV4:
+		if cfg.AWSAccessKeyID == "" || cfg.AWSSecretAccessKey == "" {
+			return fmt.Errorf("aws_access_key_id and aws_secret_access_key are required")
+		}
+		if cfg.AWSRegion == "" {
+			return fmt.Errorf("aws_region is required")
+		}
+		if cfg.AWSService == "" {
+			cfg.AWSService = DefaultAWSService
+		}
+	}
+	return nil
+}
+
+// signRequest signs req according to the test's auth, replacing any earlier
+// signature. It does nothing for other auth types.
+func signRequest(req *http.Request, cfg *AuthConfig, now time.Time) {
+	if cfg == nil || !isSigned(cfg.Type) {
+		return
+	}
+	body := requestBody(req)
+	switch cfg.Type {
+	case AuthHMAC:
+		signHMAC(req, cfg, body, now)
+	case AuthAWSSigV4:
+		signAWSSigV4(req, cfg, body, now)
+	}
+}
+
+// requestBody returns a copy of the body of req without consuming it.
+func requestBody(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+	body, _ := io.ReadAll(rc)
+	return body
+}
+
+// signHMAC sets the timestamp and the HMAC-SHA256 signature of
+// "METHOD\nPATH?QUERY\nTIMESTAMP\nBODY", with the timestamp in Unix seconds.
+func signHMAC(req *http.Request, cfg *AuthConfig, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(cfg.HMACSecret))
+	io.WriteString(mac, req.Method+"\n"+req.URL.RequestURI()+"\n"+timestamp+"\n")
+	mac.Write(body)
+	sum := mac.Sum(nil)
+
+	signature := hex.EncodeToString(sum)
+	if cfg.SignatureEncoding == SignatureBase64 {
+		signature = base64.StdEncoding.EncodeToString(sum)
+	}
+	req.Header.Set(cfg.TimestampHeader, timestamp)
+	req.Header.Set(cfg.SignatureHeader, signature)
+	if cfg.HMACKeyID != "" {
+		req.Header.Set(cfg.KeyIDHeader, cfg.HMACKeyID)
+	}
+}
+
+// signAWSSigV4 sets the Authorization header of an AWS Signature Version 4
+// request, signing the host, the date and the session token when set.
+func signAWSSigV4(req *http.Request, cfg *AuthConfig, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(awsDateFormat)
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": amzDate}
+	if req.Host != "" {
+		headers["host"] = req.Host
+	}
+	if cfg.AWSSessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cfg.AWSSessionToken)
+		headers["x-amz-security-token"] = cfg.AWSSessionToken
+	}
+	if cfg.AWSService == "s3" {
+		// S3 rejects requests without the payload hash header
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		headers["x-amz-content-sha256"] = payloadHash
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// Every service but S3 encodes the already escaped path a second time
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if cfg.AWSService != "s3" {
+		path = awsURIEncode(path, false)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + cfg.AWSRegion + "/" + cfg.AWSService + "/aws4_request"
+	stringToSign := awsSigningAlgorithm + "\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+cfg.AWSSecretAccessKey), date)
+	for _, part := range []string{cfg.AWSRegion, cfg.AWSService, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSigningAlgorithm, cfg.AWSAccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsCanonicalQuery sorts the query by name, then value, encoding both.
+func awsCanonicalQuery(query url.Values) string {
+	encoded := make(map[string][]string, len(query))
+	names := make([]string, 0, len(query))
+	for name, values := range query {
+		name = awsURIEncode(name, true)
+		for _, value := range values {
+			encoded[name] = append(encoded[name], awsURIEncode(value, true))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		values := encoded[name]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode percent-encodes every byte except the unreserved characters
+// of RFC 3986, and slashes unless encodeSlash is set.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, data)
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
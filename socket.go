@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Protocols of a socket test.
+const (
+	SocketTCP = "tcp"
+	SocketUDP = "udp"
+)
+
+// Encodings of a socket test's payload and delimiter.
+const (
+	SocketEncodingText = "text"
+	SocketEncodingHex  = "hex" // Hex digits, whitespace ignored, decoded after templates are rendered
+)
+
+const (
+	MaxSocketPayloadBytes   = 64 << 10
+	MaxSocketDelimiterBytes = 64
+	MaxSocketResponseBytes  = 64 << 10 // Longest response read while looking for its end
+
+	socketReadSize = 64 << 10 // Holds the largest UDP datagram
+)
+
+// ErrorCategorySocketResponse is recorded when a response grows past
+// MaxSocketResponseBytes without reaching its delimiter.
+const ErrorCategorySocketResponse = "socket_response_too_long"
+
+var errSocketResponseTooLong = fmt.Errorf("no end of response within %d bytes", MaxSocketResponseBytes)
+
+// SocketConfig turns a test into a socket test: every request writes Payload
+// to the test's host:port over TCP or UDP and, unless NoReply is set, reads
+// the response. Without a delimiter or length, the response is whatever the
+// first read returns: the first datagram for UDP.
+type SocketConfig struct {
+	Protocol      string `json:"protocol,omitempty"`       // "tcp" (default) or "udp"
+	Payload       string `json:"payload"`                  // Templated
+	Encoding      string `json:"encoding,omitempty"`       // "text" (default) or "hex", for the payload and delimiter
+	Delimiter     string `json:"delimiter,omitempty"`      // A response ends with it
+	ResponseBytes int    `json:"response_bytes,omitempty"` // A response is this many bytes
+	NoReply       bool   `json:"no_reply,omitempty"`       // Requests are only written
+	NewConnection bool   `json:"new_connection,omitempty"` // Connect for every request instead of once per user
+}
+
+// SocketMetrics summarises the connections of a socket test and the bytes
+// sent and received on them.
+type SocketMetrics struct {
+	Connections     int64        `json:"connections"`
+	ConnectFailures int64        `json:"connect_failures"`
+	Disconnects     int64        `json:"disconnects"` // Connections closed after an error, reopened by the next request
+	BytesSent       int64        `json:"bytes_sent"`
+	BytesReceived   int64        `json:"bytes_received"`
+	ConnectLatency  LatencyStats `json:"connect_latency"`
+	RoundTrip       LatencyStats `json:"round_trip"` // From writing the payload to reading the whole response
+}
+
+// socketTest is a compiled SocketConfig.
+type socketTest struct {
+	cfg       *SocketConfig
+	address   string // host:port
+	payload   *requestTemplate
+	delimiter []byte
+}
+
+// socketConn is a virtual user's connection to the target of a socket test.
+type socketConn struct {
+	conn    net.Conn
+	pending []byte // Received after the end of the last TCP response
+	buf     []byte
+}
+
+// socketCollector accumulates socket counters while a test runs.
+type socketCollector struct {
+	connectLatencies *hdrhistogram.Histogram
+	roundTrips       *hdrhistogram.Histogram
+	metrics          SocketMetrics
+}
+
+// validateSocketConfig checks a socket test against its host, a host:port
+// without a scheme, fills in defaults and compiles it.
+func validateSocketConfig(cfg *SocketConfig, host string) (*socketTest, error) {
+	if cfg.Protocol == "" {
+		cfg.Protocol = SocketTCP
+	}
+	if cfg.Protocol != SocketTCP && cfg.Protocol != SocketUDP {
+		return nil, fmt.Errorf("protocol must be one of %v", []string{SocketTCP, SocketUDP})
+	}
+	if cfg.Encoding == "" {
+		cfg.Encoding = SocketEncodingText
+	}
+	if cfg.Encoding != SocketEncodingText && cfg.Encoding != SocketEncodingHex {
+		return nil, fmt.Errorf("encoding must be one of %v", []string{SocketEncodingText, SocketEncodingHex})
+	}
+
+	address := strings.TrimSpace(host)
+	if strings.Contains(address, "://") {
+		return nil, fmt.Errorf("host must be host:port without a scheme")
+	}
+	hostname, port, err := net.SplitHostPort(address)
+	if err != nil || hostname == "" {
+		return nil, fmt.Errorf("host must be host:port")
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return nil, fmt.Errorf("port must be between 1 and 65535")
+	}
+
+	if cfg.Payload == "" {
+		return nil, fmt.Errorf("payload is required")
+	}
+	if len(cfg.Payload) > MaxSocketPayloadBytes {
+		return nil, fmt.Errorf("payload must be at most %d bytes", MaxSocketPayloadBytes)
+	}
+	payload, err := parseTemplate(cfg.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("payload: %v", err)
+	}
+	// Templated hex payloads are checked as each request renders them
+	if cfg.Encoding == SocketEncodingHex && !strings.Contains(cfg.Payload, "{{") {
+		if _, err := decodeSocketHex(cfg.Payload); err != nil {
+			return nil, fmt.Errorf("payload: %v", err)
+		}
+	}
+
+	if cfg.Delimiter != "" && cfg.ResponseBytes != 0 {
+		return nil, fmt.Errorf("delimiter and response_bytes cannot be combined")
+	}
+	if cfg.NoReply && (cfg.Delimiter != "" || cfg.ResponseBytes != 0) {
+		return nil, fmt.Errorf("no_reply cannot be combined with delimiter or response_bytes")
+	}
+	if cfg.ResponseBytes < 0 || cfg.ResponseBytes > MaxSocketResponseBytes {
+		return nil, fmt.Errorf("response_bytes must be between 0 and %d", MaxSocketResponseBytes)
+	}
+	delimiter := []byte(cfg.Delimiter)
+	if cfg.Encoding == SocketEncodingHex && cfg.Delimiter != "" {
+		if delimiter, err = decodeSocketHex(cfg.Delimiter); err != nil {
+			return nil, fmt.Errorf("delimiter: %v", err)
+		}
+	}
+	if len(delimiter) > MaxSocketDelimiterBytes {
+		return nil, fmt.Errorf("delimiter must be at most %d bytes", MaxSocketDelimiterBytes)
+	}
+
+	return &socketTest{cfg: cfg, address: address, payload: payload, delimiter: delimiter}, nil
+}
+
+// decodeSocketHex decodes hex digits, ignoring whitespace between them.
+func decodeSocketHex(s string) ([]byte, error) {
+	decoded, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex: %v", err)
+	}
+	if len(decoded) == 0 {
+		return nil, errors.New("hex is empty")
+	}
+	return decoded, nil
+}
+
+// render returns the bytes of one request.
+func (test *socketTest) render(user *virtualUser) ([]byte, error) {
+	payload := test.payload.render(user)
+	if test.cfg.Encoding == SocketEncodingHex {
+		return decodeSocketHex(payload)
+	}
+	return []byte(payload), nil
+}
+
+// responseEnd returns the length of the first complete response at the start
+// of received, or -1 when more is needed.
+func (test *socketTest) responseEnd(received []byte) int {
+	switch {
+	case len(test.delimiter) > 0:
+		if i := bytes.Index(received, test.delimiter); i >= 0 {
+			return i + len(test.delimiter)
+		}
+	case test.cfg.ResponseBytes > 0:
+		if len(received) >= test.cfg.ResponseBytes {
+			return test.cfg.ResponseBytes
+		}
+	default:
+		if len(received) > 0 {
+			return len(received)
+		}
+	}
+	return -1
+}
+
+// readResponse reads until a whole response has arrived. Anything a TCP
+// target sent after it is kept for the next response; the rest of a UDP
+// datagram is dropped.
+func (sc *socketConn) readResponse(test *socketTest) ([]byte, error) {
+	received := sc.pending
+	sc.pending = nil
+	if sc.buf == nil {
+		sc.buf = make([]byte, socketReadSize)
+	}
+	for {
+		if end := test.responseEnd(received); end >= 0 {
+			if test.cfg.Protocol == SocketTCP && end < len(received) {
+				sc.pending = append([]byte(nil), received[end:]...)
+			}
+			return received[:end], nil
+		}
+		if len(received) >= MaxSocketResponseBytes {
+			return received, errSocketResponseTooLong
+		}
+		n, err := sc.conn.Read(sc.buf)
+		received = append(received, sc.buf[:n]...)
+		if err != nil {
+			return received, err
+		}
+	}
+}
+
+// closeSocket closes the user's connection of a socket test, if any.
+func (user *virtualUser) closeSocket() {
+	if user.socket != nil {
+		user.socket.conn.Close()
+		user.socket = nil
+	}
+}
+
+// sendSocket sends one request of a socket test, connecting first when the
+// user has no connection, and records it. Its latency includes the connect.
+func (tm *TestManager) sendSocket(ctx context.Context, testCtx *TestContext, user *virtualUser, intendedStart time.Time) {
+	metrics := testCtx.Metrics
+	test := testCtx.Socket
+	start := time.Now()
+	if intendedStart.After(start) {
+		intendedStart = start
+	}
+	deadline := start.Add(testCtx.TestRun.Transport.RequestTimeout())
+
+	var sent, received int
+	var roundTrip time.Duration
+	disconnected := false
+	payload, err := test.render(user)
+	errorCategory := ErrorCategoryRequestError
+	if err == nil {
+		errorCategory = ""
+		if user.socket == nil {
+			err = tm.dialSocket(ctx, testCtx, user, deadline)
+		}
+	}
+	if err == nil {
+		sc := user.socket
+		// A stopping test interrupts a blocked write or read
+		stop := context.AfterFunc(ctx, func() { sc.conn.SetDeadline(time.Unix(1, 0)) })
+		sc.conn.SetDeadline(deadline)
+		written := time.Now()
+		sent, err = sc.conn.Write(payload)
+		if err == nil && !test.cfg.NoReply {
+			var response []byte
+			response, err = sc.readResponse(test)
+			received = len(response)
+		}
+		if err == nil {
+			roundTrip = time.Since(written)
+		}
+		stop()
+		// A failed connection may be out of step with the target, so the
+		// next request reconnects
+		disconnected = err != nil && ctx.Err() == nil
+		if err != nil || test.cfg.NewConnection {
+			user.closeSocket()
+		}
+	}
+	completedAt := time.Now()
+	latency := completedAt.Sub(start).Seconds() * 1000
+	correctedLatency := completedAt.Sub(intendedStart).Seconds() * 1000
+
+	success := err == nil
+	if !success && errorCategory == "" {
+		switch {
+		case ctx.Err() != nil:
+			errorCategory = ErrorCategoryContextCancelled
+		case errors.Is(err, errSocketResponseTooLong):
+			errorCategory = ErrorCategorySocketResponse
+		default:
+			errorCategory = classifyError(err)
+		}
+	}
+
+	metrics.RecordWithoutStatus(latency, correctedLatency, success)
+	metrics.RecordSocket(roundTrip, sent, received, success, disconnected)
+	if !success {
+		metrics.RecordError(errorCategory, 0, errorSample(err))
+	}
+	testCtx.Writer.Write(&RequestMetric{
+		TestRunID:        testCtx.TestRun.ID,
+		Timestamp:        completedAt,
+		Latency:          latency,
+		CorrectedLatency: correctedLatency,
+		Success:          success,
+		ErrorCategory:    errorCategory,
+	})
+}
+
+// dialSocket opens the user's connection to the target, with the transport's
+// connect timeout, and records the connect.
+func (tm *TestManager) dialSocket(ctx context.Context, testCtx *TestContext, user *virtualUser, deadline time.Time) error {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(testCtx.TestRun.Transport.ConnectTimeoutMs) * time.Millisecond,
+		Deadline:  deadline,
+		KeepAlive: 30 * time.Second,
+	}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, testCtx.Socket.cfg.Protocol, testCtx.Socket.address)
+	testCtx.Metrics.RecordSocketConnect(time.Since(start).Seconds()*1000, err == nil)
+	if err != nil {
+		return err
+	}
+	user.socket = &socketConn{conn: conn}
+	return nil
+}
+
+// RecordSocketConnect counts a connection attempt of a socket test.
+func (mc *MetricsCollector) RecordSocketConnect(latency float64, ok bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	collector := mc.socketLocked()
+	if !ok {
+		collector.metrics.ConnectFailures++
+		return
+	}
+	collector.metrics.Connections++
+	recordLatency(collector.connectLatencies, latency)
+}
+
+// RecordSocket adds the bytes of a socket request and, when it succeeded, its
+// round trip. disconnected is set when a failure closed the user's
+// connection.
+func (mc *MetricsCollector) RecordSocket(roundTrip time.Duration, sent, received int, success, disconnected bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	collector := mc.socketLocked()
+	collector.metrics.BytesSent += int64(sent)
+	collector.metrics.BytesReceived += int64(received)
+	if success {
+		recordLatency(collector.roundTrips, roundTrip.Seconds()*1000)
+	} else if disconnected {
+		collector.metrics.Disconnects++
+	}
+}
+
+// socketLocked returns the test's socket collector, creating it if needed.
+// mc.mu must be held.
+func (mc *MetricsCollector) socketLocked() *socketCollector {
+	if mc.socket == nil {
+		mc.socket = &socketCollector{
+			connectLatencies: newLatencyHistogram(mc.precision),
+			roundTrips:       newLatencyHistogram(mc.precision),
+		}
+	}
+	return mc.socket
+}
+
+// SocketMetrics returns a snapshot of the socket counters, or nil for tests
+// without socket requests.
+func (mc *MetricsCollector) SocketMetrics() *SocketMetrics {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	if mc.socket == nil {
+		return nil
+	}
+	metrics := mc.socket.metrics
+	metrics.ConnectLatency = histogramStats(mc.socket.connectLatencies)
+	metrics.RoundTrip = histogramStats(mc.socket.roundTrips)
+	return &metrics
+}
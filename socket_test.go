@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveTCP accepts one connection on a local listener and writes the first
+// chunk to it, then each further chunk once the client has sent a byte, so
+// the test controls how they are split across reads. It returns the client's
+// end of the connection.
+func serveTCP(t *testing.T, chunks ...string) net.Conn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		next := make([]byte, 1)
+		for i, chunk := range chunks {
+			if i > 0 {
+				if _, err := conn.Read(next); err != nil {
+					return
+				}
+			}
+			conn.Write([]byte(chunk))
+		}
+		// Hold the connection open until the client is done
+		conn.Read(next)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func newTestSocket(t *testing.T, cfg *SocketConfig) *socketTest {
+	test, err := validateSocketConfig(cfg, "127.0.0.1:9000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return test
+}
+
+// readResponses reads n responses from sc, failing the test on any error.
+func readResponses(t *testing.T, sc *socketConn, test *socketTest, n int) []string {
+	var responses []string
+	for i := 0; i < n; i++ {
+		response, err := sc.readResponse(test)
+		if err != nil {
+			t.Fatalf("response %d: %v", i+1, err)
+		}
+		responses = append(responses, string(response))
+	}
+	return responses
+}
+
+func TestSocketTCPDelimiter(t *testing.T) {
+	test := newTestSocket(t, &SocketConfig{Payload: "ping", Delimiter: "\r\n"})
+	// Two responses in one read, then one split across reads and its
+	// delimiter
+	conn := serveTCP(t, "one\r\ntwo\r\nth", "ree\r", "\n")
+	sc := &socketConn{conn: conn}
+
+	first, err := sc.readResponse(test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != "one\r\n" || string(sc.pending) != "two\r\nth" {
+		t.Fatalf("response = %q, pending = %q, want %q and %q", first, sc.pending, "one\r\n", "two\r\nth")
+	}
+	conn.Write([]byte("go"))
+	got := readResponses(t, sc, test, 2)
+	if got[0] != "two\r\n" || got[1] != "three\r\n" {
+		t.Errorf("responses = %q, want two and three", got)
+	}
+	if len(sc.pending) != 0 {
+		t.Errorf("pending = %q after the last response", sc.pending)
+	}
+}
+
+func TestSocketTCPResponseBytes(t *testing.T) {
+	test := newTestSocket(t, &SocketConfig{Payload: "de ad be ef", Encoding: SocketEncodingHex, ResponseBytes: 4})
+	conn := serveTCP(t, "\x00\x01\x02\x03\x04\x05", "\x06\x07")
+	sc := &socketConn{conn: conn}
+
+	conn.Write([]byte("go"))
+	got := readResponses(t, sc, test, 2)
+	if got[0] != "\x00\x01\x02\x03" || got[1] != "\x04\x05\x06\x07" {
+		t.Errorf("responses = %q, want two 4-byte responses", got)
+	}
+}
+
+func TestSocketTCPFirstRead(t *testing.T) {
+	// Without a delimiter or length, a response is whatever one read returns
+	test := newTestSocket(t, &SocketConfig{Payload: "ping"})
+	conn := serveTCP(t, "pong", "pong again")
+	sc := &socketConn{conn: conn}
+
+	got := readResponses(t, sc, test, 1)
+	conn.Write([]byte("go"))
+	got = append(got, readResponses(t, sc, test, 1)...)
+	if got[0] != "pong" || got[1] != "pong again" {
+		t.Errorf("responses = %q", got)
+	}
+}
+
+func TestSocketResponseTooLong(t *testing.T) {
+	test := newTestSocket(t, &SocketConfig{Payload: "ping", Delimiter: "\n"})
+	sc := &socketConn{conn: serveTCP(t, string(bytes.Repeat([]byte("x"), MaxSocketResponseBytes+1)))}
+
+	if _, err := sc.readResponse(test); err != errSocketResponseTooLong {
+		t.Errorf("err = %v, want %v", err, errSocketResponseTooLong)
+	}
+}
+
+func TestSocketUDP(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// Each reply is a datagram of its own, with trailing bytes
+			// after the delimiter
+			server.WriteTo(append([]byte("echo "+string(buf[:n])), "\nrest"...), addr)
+		}
+	}()
+
+	conn, err := net.Dial("udp", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	for _, c := range []struct {
+		cfg  SocketConfig
+		want string
+	}{
+		{SocketConfig{Protocol: SocketUDP, Payload: "a"}, "echo a\nrest"},
+		{SocketConfig{Protocol: SocketUDP, Payload: "b", Delimiter: "\n"}, "echo b\n"},
+	} {
+		test := newTestSocket(t, &c.cfg)
+		sc := &socketConn{conn: conn}
+		if _, err := conn.Write([]byte(c.cfg.Payload)); err != nil {
+			t.Fatal(err)
+		}
+		response, err := sc.readResponse(test)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(response) != c.want {
+			t.Errorf("response = %q, want %q", response, c.want)
+		}
+		// The rest of a datagram is not part of the next response
+		if len(sc.pending) != 0 {
+			t.Errorf("pending = %q after a datagram", sc.pending)
+		}
+	}
+}
+
+func TestSocketRender(t *testing.T) {
+	user := newVirtualUser(&TestContext{}, false)
+	test := newTestSocket(t, &SocketConfig{Payload: "ca fe 0{{vu}}", Encoding: SocketEncodingHex})
+	payload, err := test.render(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload, []byte{0xca, 0xfe, 0x01}) {
+		t.Errorf("payload = % x, want ca fe 01", payload)
+	}
+
+	test = newTestSocket(t, &SocketConfig{Payload: "ab {{vu}}", Encoding: SocketEncodingHex})
+	if _, err := test.render(user); err == nil {
+		t.Error("odd-length hex rendered without an error")
+	}
+}
+
+func TestValidateSocketConfigErrors(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		cfg  SocketConfig
+		host string
+	}{
+		{"scheme", SocketConfig{Payload: "x"}, "tcp://127.0.0.1:9000"},
+		{"no port", SocketConfig{Payload: "x"}, "127.0.0.1"},
+		{"port range", SocketConfig{Payload: "x"}, "127.0.0.1:70000"},
+		{"protocol", SocketConfig{Protocol: "sctp", Payload: "x"}, "127.0.0.1:9000"},
+		{"no payload", SocketConfig{}, "127.0.0.1:9000"},
+		{"invalid hex", SocketConfig{Payload: "zz", Encoding: SocketEncodingHex}, "127.0.0.1:9000"},
+		{"delimiter and length", SocketConfig{Payload: "x", Delimiter: "\n", ResponseBytes: 4}, "127.0.0.1:9000"},
+		{"no reply with delimiter", SocketConfig{Payload: "x", Delimiter: "\n", NoReply: true}, "127.0.0.1:9000"},
+		{"response bytes", SocketConfig{Payload: "x", ResponseBytes: MaxSocketResponseBytes + 1}, "127.0.0.1:9000"},
+	} {
+		if _, err := validateSocketConfig(&c.cfg, c.host); err == nil {
+			t.Errorf("%s: validateSocketConfig() succeeded", c.name)
+		}
+	}
+}
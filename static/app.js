@@ -4,6 +4,7 @@ let timeSeriesInterval = null;
 let throughputChart = null;
 let latencyChart = null;
 let successRateChart = null;
+let loadProfileChart = null;
 let showAdvancedMetrics = false;
 let expandedHistoryItems = new Set();
 let testStartTime = null;
@@ -307,6 +308,9 @@ const updateMetricsThrottled = throttle(function (metrics) {
 
   requestAnimationFrame(() => {
     try {
+      if (domCache.virtualUsers && metrics.active_users !== undefined) {
+        domCache.virtualUsers.textContent = metrics.active_users;
+      }
       if (domCache.totalRequests) {
         domCache.totalRequests.textContent = (
           metrics.total_requests || 0
@@ -417,6 +421,8 @@ const updateChartsThrottled = throttle(function (timeSeries) {
   const rpsData = new Array(dataLength);
   const latencyData = new Array(dataLength);
   const successRateData = new Array(dataLength);
+  const targetLoadData = new Array(dataLength);
+  const activeUsersData = new Array(dataLength);
 
   for (let i = 0; i < dataLength; i++) {
     const point = recentData[i];
@@ -424,6 +430,8 @@ const updateChartsThrottled = throttle(function (timeSeries) {
     rpsData[i] = point.rps;
     latencyData[i] = point.avg_latency;
     successRateData[i] = point.success_rate;
+    targetLoadData[i] = point.target_load;
+    activeUsersData[i] = point.active_users;
   }
 
   requestAnimationFrame(() => {
@@ -452,6 +460,13 @@ const updateChartsThrottled = throttle(function (timeSeries) {
         console.warn("[Charts] Success rate chart not initialized");
       }
 
+      if (loadProfileChart) {
+        loadProfileChart.data.labels = labels;
+        loadProfileChart.data.datasets[0].data = targetLoadData;
+        loadProfileChart.data.datasets[1].data = activeUsersData;
+        loadProfileChart.update("none");
+      }
+
       console.log("[Charts] ✅ Charts updated successfully");
     } catch (error) {
       console.error("[Charts] Error updating charts:", error);
@@ -479,6 +494,11 @@ function resetCharts() {
     successRateChart.data.datasets[0].data = [];
     successRateChart.update();
   }
+  if (loadProfileChart) {
+    loadProfileChart.data.labels = [];
+    loadProfileChart.data.datasets.forEach((dataset) => (dataset.data = []));
+    loadProfileChart.update();
+  }
 }
 
 function startMetricsPolling() {
@@ -1535,6 +1555,42 @@ function initializeCharts() {
       options: getChartOptions("Success Rate", "%", 100),
     });
 
+    // Load Profile Chart (target from the stage profile vs. active users/workers)
+    const loadProfileCanvas = document.getElementById("loadProfileChart");
+    if (loadProfileCanvas) {
+      loadProfileChart = new Chart(loadProfileCanvas.getContext("2d"), {
+        type: "line",
+        data: {
+          labels: [],
+          datasets: [
+            {
+              label: "Target",
+              data: [],
+              borderColor: "rgb(245, 158, 11)",
+              backgroundColor: "rgba(245, 158, 11, 0.1)",
+              borderDash: [6, 4],
+              tension: 0,
+              fill: false,
+              borderWidth: 2,
+              pointRadius: 0,
+            },
+            {
+              label: "Active",
+              data: [],
+              borderColor: "rgb(59, 130, 246)",
+              backgroundColor: "rgba(59, 130, 246, 0.1)",
+              tension: 0.4,
+              fill: true,
+              borderWidth: 2,
+              pointRadius: 0,
+              pointHoverRadius: 6,
+            },
+          ],
+        },
+        options: getChartOptions("Load", ""),
+      });
+    }
+
     console.log("[Charts] ✅ All charts initialized successfully");
   } catch (error) {
     console.error("[Charts] Error initializing charts:", error);
@@ -1990,6 +2046,14 @@ window.addEventListener("DOMContentLoaded", async () => {
       successRateChart.options.scales.y.ticks.color = textColor;
       successRateChart.update("none");
     }
+
+    if (loadProfileChart) {
+      loadProfileChart.options.scales.x.grid.color = gridColor;
+      loadProfileChart.options.scales.y.grid.color = gridColor;
+      loadProfileChart.options.scales.x.ticks.color = textColor;
+      loadProfileChart.options.scales.y.ticks.color = textColor;
+      loadProfileChart.update("none");
+    }
   }
 
   // Event delegation for history items
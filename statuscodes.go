@@ -0,0 +1,48 @@
+package main
+
+// StatusClassCounts counts responses by status class. NoResponse counts
+// requests that failed before a status code was received.
+type StatusClassCounts struct {
+	Status2xx  int64 `json:"2xx"`
+	Status3xx  int64 `json:"3xx"`
+	Status4xx  int64 `json:"4xx"`
+	Status5xx  int64 `json:"5xx"`
+	NoResponse int64 `json:"no_response"`
+	Other      int64 `json:"other,omitempty"` // 1xx and non-standard codes
+}
+
+func (c *StatusClassCounts) add(statusCode int, count int64) {
+	switch {
+	case statusCode == 0:
+		c.NoResponse += count
+	case statusCode >= 200 && statusCode < 300:
+		c.Status2xx += count
+	case statusCode >= 300 && statusCode < 400:
+		c.Status3xx += count
+	case statusCode >= 400 && statusCode < 500:
+		c.Status4xx += count
+	case statusCode >= 500 && statusCode < 600:
+		c.Status5xx += count
+	default:
+		c.Other += count
+	}
+}
+
+// statusClasses groups per-code counts into classes.
+func statusClasses(codes map[int]int64) StatusClassCounts {
+	var classes StatusClassCounts
+	for code, count := range codes {
+		classes.add(code, count)
+	}
+	return classes
+}
+
+// statusCodesFromMetrics counts stored request metrics by status code, for
+// tests recorded before the distribution was persisted.
+func statusCodesFromMetrics(metrics []*RequestMetric) map[int]int64 {
+	codes := make(map[int]int64)
+	for _, m := range metrics {
+		codes[m.StatusCode]++
+	}
+	return codes
+}
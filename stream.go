@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Formats of a streamed response.
+const (
+	StreamFormatSSE   = "sse"   // text/event-stream: an event ends with a blank line (default)
+	StreamFormatLines = "lines" // Chunked response with one event per non-empty line, e.g. NDJSON
+)
+
+const (
+	DefaultStreamDurationMs = 60000
+	MaxStreamDurationMs     = 600000
+	MaxStreamEvents         = 1000000
+
+	streamBufferSize = 64 << 10 // Longer lines are read in pieces
+)
+
+// Error categories of streams that received a response but did not complete.
+const (
+	ErrorCategoryStreamAborted  = "stream_aborted"      // The stream broke off before the server ended it
+	ErrorCategoryStreamIdle     = "stream_idle_timeout" // No data arrived within idle_timeout_ms
+	ErrorCategoryStreamNoEvents = "stream_no_events"    // The stream ended without an event
+)
+
+// Causes of the cancellation of a stream's request.
+var (
+	errStreamHeaderTimeout = errors.New("no response headers within request_timeout_ms")
+	errStreamMaxDuration   = errors.New("stream reached max_duration_ms")
+	errStreamIdle          = errors.New("no data within idle_timeout_ms")
+)
+
+// StreamConfig makes a test's requests streams: each response is kept open
+// and read as it arrives, until the server ends it or a limit is reached.
+type StreamConfig struct {
+	Format        string `json:"format,omitempty"`          // "sse" or "lines"
+	MaxDurationMs int    `json:"max_duration_ms,omitempty"` // Streams still open after this long are closed as complete (default: 60000)
+	IdleTimeoutMs int    `json:"idle_timeout_ms,omitempty"` // Longest wait for data before the stream fails; 0 for no limit
+	MaxEvents     int    `json:"max_events,omitempty"`      // Streams are closed as complete after this many events; 0 for no limit
+}
+
+// StreamMetrics summarises the streams of a test. Timings are measured from
+// when the request was sent, except the gaps between consecutive events.
+type StreamMetrics struct {
+	Streams    int64        `json:"streams"`
+	Completed  int64        `json:"completed"` // Ended by the server, a limit or the end of the test after at least one event
+	Aborted    int64        `json:"aborted"`
+	Events     int64        `json:"events"`
+	MinEvents  int64        `json:"min_events"` // Events per stream
+	MaxEvents  int64        `json:"max_events"`
+	AvgEvents  float64      `json:"avg_events"`
+	FirstByte  LatencyStats `json:"first_byte"`
+	FirstEvent LatencyStats `json:"first_event"`
+	EventGap   LatencyStats `json:"event_gap"`
+	Duration   LatencyStats `json:"duration"`
+}
+
+// streamCollector accumulates the streams of a test while it runs. The gaps
+// between events are recorded as each event arrives, so eventGap has a lock
+// of its own instead of taking mc.mu for every event.
+type streamCollector struct {
+	firstByte, firstEvent *hdrhistogram.Histogram
+	duration              *hdrhistogram.Histogram
+	metrics               StreamMetrics
+	gapMu                 sync.Mutex
+	eventGap              *hdrhistogram.Histogram
+}
+
+// streamResult is what was read of one stream. firstByte and firstEvent are
+// zero when none arrived.
+type streamResult struct {
+	firstByte, firstEvent time.Duration
+	duration              time.Duration
+	events                int64
+	bytes                 int64
+	err                   error // Read error, nil when the server ended the stream or max_events was reached
+}
+
+// streamWatch bounds a stream's request: until the response headers arrive by
+// the transport's request timeout, then by the stream's limits. Reaching one
+// cancels the request with the limit as the cause.
+type streamWatch struct {
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	mu       sync.Mutex
+	deadline *time.Timer
+	idle     *time.Timer
+}
+
+// validateStreamConfig checks the limits of a stream test and fills in
+// defaults.
+func validateStreamConfig(cfg *StreamConfig) error {
+	if cfg.Format == "" {
+		cfg.Format = StreamFormatSSE
+	}
+	if cfg.Format != StreamFormatSSE && cfg.Format != StreamFormatLines {
+		return fmt.Errorf("format must be one of %v", []string{StreamFormatSSE, StreamFormatLines})
+	}
+	if cfg.MaxDurationMs == 0 {
+		cfg.MaxDurationMs = DefaultStreamDurationMs
+	}
+	if cfg.MaxDurationMs < 0 || cfg.MaxDurationMs > MaxStreamDurationMs {
+		return fmt.Errorf("max_duration_ms must be between 0 and %d", MaxStreamDurationMs)
+	}
+	if cfg.IdleTimeoutMs < 0 || cfg.IdleTimeoutMs > cfg.MaxDurationMs {
+		return fmt.Errorf("idle_timeout_ms must be between 0 and max_duration_ms")
+	}
+	if cfg.MaxEvents < 0 || cfg.MaxEvents > MaxStreamEvents {
+		return fmt.Errorf("max_events must be between 0 and %d", MaxStreamEvents)
+	}
+	return nil
+}
+
+func newStreamWatch(parent context.Context, headerTimeout time.Duration) *streamWatch {
+	w := &streamWatch{}
+	w.ctx, w.cancel = context.WithCancelCause(parent)
+	w.deadline = time.AfterFunc(headerTimeout, func() { w.cancel(errStreamHeaderTimeout) })
+	return w
+}
+
+// started replaces the header timeout with the stream's limits once the
+// response headers have arrived.
+func (w *streamWatch) started(cfg *StreamConfig) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.deadline.Stop()
+	w.deadline = time.AfterFunc(time.Duration(cfg.MaxDurationMs)*time.Millisecond, func() { w.cancel(errStreamMaxDuration) })
+	w.resetIdleLocked(cfg)
+}
+
+// received restarts the idle timeout after data arrived.
+func (w *streamWatch) received(cfg *StreamConfig) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.resetIdleLocked(cfg)
+}
+
+func (w *streamWatch) resetIdleLocked(cfg *StreamConfig) {
+	if cfg.IdleTimeoutMs <= 0 {
+		return
+	}
+	if w.idle != nil {
+		w.idle.Stop()
+	}
+	w.idle = time.AfterFunc(time.Duration(cfg.IdleTimeoutMs)*time.Millisecond, func() { w.cancel(errStreamIdle) })
+}
+
+// stop releases the timers and the request's context.
+func (w *streamWatch) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.deadline.Stop()
+	if w.idle != nil {
+		w.idle.Stop()
+	}
+	w.cancel(nil)
+}
+
+// timedOut reports whether the request was cancelled because no response
+// headers arrived in time.
+func (w *streamWatch) timedOut() bool {
+	return context.Cause(w.ctx) == errStreamHeaderTimeout
+}
+
+// failure returns the error category and message of a stream, or empty
+// strings when it completed. testCtx is the context of the test, which ends
+// open streams when the test stops.
+func (w *streamWatch) failure(testCtx context.Context, result streamResult) (string, string) {
+	if result.err != nil {
+		cause := context.Cause(w.ctx)
+		switch {
+		case cause == errStreamIdle:
+			return ErrorCategoryStreamIdle, cause.Error()
+		case cause == errStreamMaxDuration, testCtx.Err() != nil:
+			// Closed by us rather than broken off
+		default:
+			return ErrorCategoryStreamAborted, errorSample(result.err)
+		}
+	}
+	if result.events == 0 {
+		if testCtx.Err() != nil {
+			return ErrorCategoryContextCancelled, "test stopped before the first event"
+		}
+		return ErrorCategoryStreamNoEvents, fmt.Sprintf("stream ended after %d bytes without an event", result.bytes)
+	}
+	return "", ""
+}
+
+// readStream reads a streamed body until it ends, counting its events and
+// timing them from start. The gaps between events are recorded in metrics as
+// they arrive.
+func readStream(body io.Reader, cfg *StreamConfig, watch *streamWatch, start time.Time, metrics *MetricsCollector) streamResult {
+	var result streamResult
+	var lastEvent time.Time
+	var collector *streamCollector // Fetched at the first gap
+	event := func(at time.Time) {
+		if result.events == 0 {
+			result.firstEvent = at.Sub(start)
+		} else {
+			if collector == nil {
+				collector = metrics.streamCollector()
+			}
+			collector.recordGap(at.Sub(lastEvent).Seconds() * 1000)
+		}
+		result.events++
+		lastEvent = at
+	}
+
+	reader := bufio.NewReaderSize(body, streamBufferSize)
+	lineStart, lineData, lineEmpty := true, false, true
+	pending := false // SSE data lines since the last event
+	for cfg.MaxEvents == 0 || result.events < int64(cfg.MaxEvents) {
+		chunk, err := reader.ReadSlice('\n')
+		now := time.Now()
+		if len(chunk) > 0 {
+			if result.bytes == 0 {
+				result.firstByte = now.Sub(start)
+			}
+			result.bytes += int64(len(chunk))
+			watch.received(cfg)
+
+			complete := chunk[len(chunk)-1] == '\n'
+			content := chunk
+			if complete {
+				content = bytes.TrimRight(chunk, "\r\n")
+			}
+			if lineStart {
+				lineData = bytes.HasPrefix(content, []byte("data:")) || bytes.Equal(content, []byte("data"))
+			}
+			lineEmpty = lineEmpty && len(content) == 0
+			lineStart = complete
+			if complete {
+				switch {
+				case cfg.Format == StreamFormatLines:
+					if !lineEmpty {
+						event(now)
+					}
+				case lineEmpty:
+					// A blank line dispatches the event its data lines built
+					if pending {
+						event(now)
+					}
+					pending = false
+				case lineData:
+					pending = true
+				}
+				lineEmpty = true
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				result.err = err
+			} else if cfg.Format == StreamFormatLines && !lineEmpty {
+				// The last line needs no newline
+				event(now)
+			}
+			break
+		}
+	}
+	result.duration = time.Since(start)
+	return result
+}
+
+// RecordStream adds a stream that received a response to the test's stream
+// metrics.
+func (mc *MetricsCollector) RecordStream(result streamResult, completed bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	s := mc.streamLocked()
+	if s.metrics.Streams == 0 || result.events < s.metrics.MinEvents {
+		s.metrics.MinEvents = result.events
+	}
+	if result.events > s.metrics.MaxEvents {
+		s.metrics.MaxEvents = result.events
+	}
+	s.metrics.Streams++
+	if completed {
+		s.metrics.Completed++
+	} else {
+		s.metrics.Aborted++
+	}
+	s.metrics.Events += result.events
+
+	if result.bytes > 0 {
+		recordLatency(s.firstByte, result.firstByte.Seconds()*1000)
+	}
+	if result.events > 0 {
+		recordLatency(s.firstEvent, result.firstEvent.Seconds()*1000)
+	}
+	recordLatency(s.duration, result.duration.Seconds()*1000)
+}
+
+// recordGap adds the time between two consecutive events of a stream.
+func (s *streamCollector) recordGap(gap float64) {
+	s.gapMu.Lock()
+	defer s.gapMu.Unlock()
+
+	recordLatency(s.eventGap, gap)
+}
+
+// streamCollector returns the test's stream collector, creating it if needed.
+func (mc *MetricsCollector) streamCollector() *streamCollector {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return mc.streamLocked()
+}
+
+// streamLocked returns the test's stream collector, creating it if needed.
+// mc.mu must be held.
+func (mc *MetricsCollector) streamLocked() *streamCollector {
+	if mc.stream == nil {
+		mc.stream = &streamCollector{
+			firstByte:  newLatencyHistogram(mc.precision),
+			firstEvent: newLatencyHistogram(mc.precision),
+			eventGap:   newLatencyHistogram(mc.precision),
+			duration:   newLatencyHistogram(mc.precision),
+		}
+	}
+	return mc.stream
+}
+
+// StreamMetrics returns a snapshot of the test's stream metrics, or nil for
+// tests that read no stream.
+func (mc *MetricsCollector) StreamMetrics() *StreamMetrics {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	if mc.stream == nil {
+		return nil
+	}
+	metrics := mc.stream.metrics
+	if metrics.Streams > 0 {
+		// The collector is created by the first gap between events, so it
+		// can exist before any stream has ended and been counted
+		metrics.AvgEvents = float64(metrics.Events) / float64(metrics.Streams)
+	}
+	metrics.FirstByte = histogramStats(mc.stream.firstByte)
+	metrics.FirstEvent = histogramStats(mc.stream.firstEvent)
+	metrics.Duration = histogramStats(mc.stream.duration)
+	mc.stream.gapMu.Lock()
+	metrics.EventGap = histogramStats(mc.stream.eventGap)
+	mc.stream.gapMu.Unlock()
+	return &metrics
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxRandomStringLength = 1024
+
+const randomStringChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+var (
+	fakeFirstNames = []string{
+		"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "David", "Elizabeth",
+		"William", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Daniel", "Karen",
+		"Amara", "Chidi", "Fatima", "Kwame", "Aisha", "Tunde", "Mei", "Hiroshi", "Priya", "Arjun",
+	}
+	fakeLastNames = []string{
+		"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+		"Hernandez", "Lopez", "Wilson", "Anderson", "Thomas", "Taylor", "Moore", "Jackson", "Martin", "Lee",
+		"Okafor", "Adeyemi", "Mensah", "Nakamura", "Chen", "Patel", "Singh", "Kim", "Nguyen", "Silva",
+	}
+)
+
+// virtualUser is the per-user state templates are evaluated against. With the
+// arrival-rate executor every iteration is a new virtual user.
+type virtualUser struct {
+	id        int64
+	iteration int64
+	vars      map[string]string // Values saved by extractors, created on first use
+	row       map[string]string // Current row of the test's data
+	tokens    *tokenSource      // OAuth2 tokens of a per-user cache, created on first use
+	rng       *rand.Rand        // Private source; nil uses the shared one
+	sequence  *atomic.Int64     // Test-wide counter behind {{sequence}}
+	socket    *socketConn       // Connection of a socket test, reused by the user's requests
+}
+
+// newVirtualUser starts a user of the test. Long-lived users get a private
+// random source so templates do not contend on the shared one.
+func newVirtualUser(testCtx *TestContext, privateRand bool) *virtualUser {
+	user := &virtualUser{
+		id:       testCtx.UserIDs.Add(1),
+		sequence: &testCtx.Sequence,
+	}
+	if privateRand {
+		user.rng = rand.New(rand.NewSource(time.Now().UnixNano() + user.id))
+	}
+	return user
+}
+
+func (u *virtualUser) intn(n int) int {
+	if u.rng != nil {
+		return u.rng.Intn(n)
+	}
+	return rand.Intn(n)
+}
+
+// templateFunc describes a built-in template function. Integer arguments are
+// parsed when the template is compiled.
+type templateFunc struct {
+	minArgs, maxArgs int // maxArgs < 0 allows any number
+	intArgs          bool
+	call             func(b *strings.Builder, u *virtualUser, e *templateExpr)
+}
+
+var templateFuncs = map[string]templateFunc{
+	"randomInt": {minArgs: 2, maxArgs: 2, intArgs: true, call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		low, high := e.ints[0], e.ints[1]
+		b.WriteString(strconv.FormatInt(low+int64(u.intn(int(high-low+1))), 10))
+	}},
+	"randomString": {minArgs: 1, maxArgs: 1, intArgs: true, call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		for i := int64(0); i < e.ints[0]; i++ {
+			b.WriteByte(randomStringChars[u.intn(len(randomStringChars))])
+		}
+	}},
+	"randomChoice": {minArgs: 1, maxArgs: -1, call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		b.WriteString(e.args[u.intn(len(e.args))])
+	}},
+	"uuid": {call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		if u.rng != nil {
+			if id, err := uuid.NewRandomFromReader(u.rng); err == nil {
+				b.WriteString(id.String())
+				return
+			}
+		}
+		b.WriteString(uuid.New().String())
+	}},
+	"timestamp": {call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		b.WriteString(strconv.FormatInt(time.Now().UnixMilli(), 10))
+	}},
+	"isoTimestamp": {call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		b.WriteString(time.Now().UTC().Format(time.RFC3339Nano))
+	}},
+	"sequence": {call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		b.WriteString(strconv.FormatInt(u.sequence.Add(1), 10))
+	}},
+	"vu": {call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		b.WriteString(strconv.FormatInt(u.id, 10))
+	}},
+	"iteration": {call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		b.WriteString(strconv.FormatInt(u.iteration, 10))
+	}},
+	"firstName": {call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		b.WriteString(fakeFirstNames[u.intn(len(fakeFirstNames))])
+	}},
+	"lastName": {call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		b.WriteString(fakeLastNames[u.intn(len(fakeLastNames))])
+	}},
+	"fullName": {call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		b.WriteString(fakeFirstNames[u.intn(len(fakeFirstNames))])
+		b.WriteByte(' ')
+		b.WriteString(fakeLastNames[u.intn(len(fakeLastNames))])
+	}},
+	"email": {call: func(b *strings.Builder, u *virtualUser, e *templateExpr) {
+		b.WriteString(strings.ToLower(fakeFirstNames[u.intn(len(fakeFirstNames))]))
+		b.WriteByte('.')
+		b.WriteString(strings.ToLower(fakeLastNames[u.intn(len(fakeLastNames))]))
+		b.WriteString(strconv.Itoa(u.intn(10000)))
+		b.WriteString("@example.com")
+	}},
+}
+
+// requestTemplate is a URL, header value or body parsed once when the test
+// starts, so each request only concatenates literals and evaluated
+// expressions.
+type requestTemplate struct {
+	raw   string
+	parts []templatePart // Empty when raw has no expressions
+}
+
+// templatePart is a literal, or an expression when expr is set.
+type templatePart struct {
+	literal string
+	expr    *templateExpr
+}
+
+// templateExpr is a {{...}} expression: a built-in function call, or a
+// variable reference when fn is nil.
+type templateExpr struct {
+	raw  string // Original text including braces, kept for unknown variables
+	name string
+	fn   *templateFunc
+	args []string
+	ints []int64
+}
+
+// parseTemplate compiles s. Expressions are {{name}} for a variable or
+// {{function arg...}} for a built-in; arguments are separated by spaces and
+// may be double-quoted.
+func parseTemplate(s string) (*requestTemplate, error) {
+	t := &requestTemplate{raw: s}
+	if !strings.Contains(s, "{{") {
+		return t, nil
+	}
+	rest := s
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start+2:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated {{ in %q", s)
+		}
+		expr, err := parseTemplateExpr(rest[start : start+4+end])
+		if err != nil {
+			return nil, err
+		}
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{literal: rest[:start]})
+		}
+		t.parts = append(t.parts, templatePart{expr: expr})
+		rest = rest[start+4+end:]
+	}
+	if rest != "" {
+		t.parts = append(t.parts, templatePart{literal: rest})
+	}
+	return t, nil
+}
+
+func parseTemplateExpr(raw string) (*templateExpr, error) {
+	tokens, err := splitTemplateArgs(raw[2 : len(raw)-2])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", raw, err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression %s", raw)
+	}
+
+	expr := &templateExpr{raw: raw, name: tokens[0], args: tokens[1:]}
+	fn, ok := templateFuncs[expr.name]
+	if !ok {
+		if len(expr.args) > 0 {
+			return nil, fmt.Errorf("unknown function %q in %s", expr.name, raw)
+		}
+		return expr, nil
+	}
+	if len(expr.args) < fn.minArgs || (fn.maxArgs >= 0 && len(expr.args) > fn.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments in %s", raw)
+	}
+	if fn.intArgs {
+		for _, arg := range expr.args {
+			value, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("argument %q in %s is not an integer", arg, raw)
+			}
+			expr.ints = append(expr.ints, value)
+		}
+	}
+	switch expr.name {
+	case "randomInt":
+		if expr.ints[0] > expr.ints[1] || expr.ints[1]-expr.ints[0] >= 1<<31 {
+			return nil, fmt.Errorf("invalid range in %s", raw)
+		}
+	case "randomString":
+		if expr.ints[0] < 1 || expr.ints[0] > maxRandomStringLength {
+			return nil, fmt.Errorf("length in %s must be between 1 and %d", raw, maxRandomStringLength)
+		}
+	}
+	expr.fn = &fn
+	return expr, nil
+}
+
+// splitTemplateArgs splits an expression on spaces, keeping double-quoted
+// arguments together.
+func splitTemplateArgs(s string) ([]string, error) {
+	var tokens []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return tokens, nil
+		}
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			tokens = append(tokens, s[1:end+1])
+			s = s[end+2:]
+			continue
+		}
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		tokens = append(tokens, s[:end])
+		s = s[end:]
+	}
+}
+
+// render evaluates the template for one request. Variables are looked up in
+// the user's extracted values, then its data row; unknown ones are left as
+// written.
+func (t *requestTemplate) render(u *virtualUser) string {
+	if len(t.parts) == 0 {
+		return t.raw
+	}
+	var b strings.Builder
+	b.Grow(len(t.raw))
+	for _, part := range t.parts {
+		switch {
+		case part.expr == nil:
+			b.WriteString(part.literal)
+		case part.expr.fn != nil:
+			part.expr.fn.call(&b, u, part.expr)
+		default:
+			if value, ok := u.vars[part.expr.name]; ok {
+				b.WriteString(value)
+			} else if value, ok := u.row[part.expr.name]; ok {
+				b.WriteString(value)
+			} else {
+				b.WriteString(part.expr.raw)
+			}
+		}
+	}
+	return b.String()
+}
+
+// compileHeaderTemplates parses the values of a header map.
+func compileHeaderTemplates(headers map[string]string) (map[string]*requestTemplate, error) {
+	templates := make(map[string]*requestTemplate, len(headers))
+	for key, value := range headers {
+		t, err := parseTemplate(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %v", key, err)
+		}
+		templates[key] = t
+	}
+	return templates, nil
+}
+
+// compileStepTemplates parses a step's URL, header values and body.
+func compileStepTemplates(step *ScenarioStep) error {
+	if !expressionsOnlyInPath(step.URL) {
+		return fmt.Errorf("template expressions are only allowed in the url path and query")
+	}
+	var err error
+	if step.url, err = parseTemplate(step.URL); err != nil {
+		return fmt.Errorf("url: %v", err)
+	}
+	if step.body, err = parseTemplate(step.Body); err != nil {
+		return fmt.Errorf("body: %v", err)
+	}
+	if step.headers, err = compileHeaderTemplates(step.Headers); err != nil {
+		return err
+	}
+	if step.GraphQL != nil {
+		if step.graphql, err = validateGraphQLRequest(step.GraphQL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expressionsOnlyInPath reports whether every {{ expression in an absolute
+// URL comes after its scheme and host, so templates cannot change the target.
+func expressionsOnlyInPath(rawURL string) bool {
+	ref := strings.Index(rawURL, "{{")
+	if ref < 0 {
+		return true
+	}
+	authority := strings.Index(rawURL, "://")
+	if authority < 0 {
+		return false
+	}
+	authority += len("://")
+	pathStart := strings.IndexAny(rawURL[authority:], "/?#")
+	return pathStart >= 0 && ref > authority+pathStart
+}
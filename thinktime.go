@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Think time distributions.
+const (
+	ThinkTimeConstant    = "constant"    // Always DurationMs
+	ThinkTimeUniform     = "uniform"     // Evenly spread between MinMs and MaxMs
+	ThinkTimeNormal      = "normal"      // Bell curve around MeanMs with StdDevMs spread
+	ThinkTimeExponential = "exponential" // Averaging MeanMs, mostly short with a long tail (Poisson arrivals)
+)
+
+const MaxPacingMs = 60000 // Maximum iteration duration set by pacing
+
+// ThinkTime is the pause a virtual user takes after a request, modelling the
+// time a person spends reading a page before the next click. Random samples
+// are clamped to MinMs and MaxMs when those are set, and always to
+// MaxThinkTimeMs.
+type ThinkTime struct {
+	Type       string `json:"type"`
+	DurationMs int    `json:"duration_ms,omitempty"` // constant
+	MinMs      int    `json:"min_ms,omitempty"`      // uniform, or lower bound for normal and exponential
+	MaxMs      int    `json:"max_ms,omitempty"`      // uniform, or upper bound for normal and exponential
+	MeanMs     int    `json:"mean_ms,omitempty"`     // normal and exponential
+	StdDevMs   int    `json:"stddev_ms,omitempty"`   // normal
+}
+
+// validateThinkTime checks a think time configuration.
+func validateThinkTime(t *ThinkTime) error {
+	for _, v := range []int{t.DurationMs, t.MinMs, t.MaxMs, t.MeanMs, t.StdDevMs} {
+		if v < 0 || v > MaxThinkTimeMs {
+			return fmt.Errorf("think time values must be between 0 and %d ms", MaxThinkTimeMs)
+		}
+	}
+	if t.MaxMs > 0 && t.MinMs > t.MaxMs {
+		return fmt.Errorf("min_ms must not exceed max_ms")
+	}
+
+	switch t.Type {
+	case ThinkTimeConstant:
+		if t.DurationMs == 0 {
+			return fmt.Errorf("constant think time requires duration_ms")
+		}
+	case ThinkTimeUniform:
+		if t.MaxMs == 0 {
+			return fmt.Errorf("uniform think time requires max_ms")
+		}
+	case ThinkTimeNormal:
+		if t.MeanMs == 0 || t.StdDevMs == 0 {
+			return fmt.Errorf("normal think time requires mean_ms and stddev_ms")
+		}
+	case ThinkTimeExponential:
+		if t.MeanMs == 0 {
+			return fmt.Errorf("exponential think time requires mean_ms")
+		}
+	default:
+		return fmt.Errorf("type must be one of %v", []string{ThinkTimeConstant, ThinkTimeUniform, ThinkTimeNormal, ThinkTimeExponential})
+	}
+	return nil
+}
+
+// sample draws one pause from the distribution using the user's random
+// source.
+func (t *ThinkTime) sample(u *virtualUser) time.Duration {
+	var ms float64
+	switch t.Type {
+	case ThinkTimeConstant:
+		return time.Duration(t.DurationMs) * time.Millisecond
+	case ThinkTimeUniform:
+		ms = float64(t.MinMs) + u.float64()*float64(t.MaxMs-t.MinMs)
+	case ThinkTimeNormal:
+		ms = float64(t.MeanMs) + u.normFloat64()*float64(t.StdDevMs)
+	case ThinkTimeExponential:
+		ms = u.expFloat64() * float64(t.MeanMs)
+	}
+
+	high := float64(MaxThinkTimeMs)
+	if t.MaxMs > 0 {
+		high = float64(t.MaxMs)
+	}
+	ms = math.Max(float64(t.MinMs), math.Min(ms, high))
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+func (u *virtualUser) float64() float64 {
+	if u.rng != nil {
+		return u.rng.Float64()
+	}
+	return rand.Float64()
+}
+
+func (u *virtualUser) normFloat64() float64 {
+	if u.rng != nil {
+		return u.rng.NormFloat64()
+	}
+	return rand.NormFloat64()
+}
+
+func (u *virtualUser) expFloat64() float64 {
+	if u.rng != nil {
+		return u.rng.ExpFloat64()
+	}
+	return rand.ExpFloat64()
+}
+
+// stepPause returns how long a user waits after a step's request, which
+// started at sent. A step's pacing or think time overrides the test's think
+// time.
+func stepPause(step *ScenarioStep, testThinkTime *ThinkTime, user *virtualUser, sent time.Time) time.Duration {
+	switch {
+	case step.PacingMs > 0:
+		return time.Until(sent.Add(time.Duration(step.PacingMs) * time.Millisecond))
+	case step.ThinkTime != nil:
+		return step.ThinkTime.sample(user)
+	case step.ThinkTimeMs > 0:
+		return time.Duration(step.ThinkTimeMs) * time.Millisecond
+	case testThinkTime != nil:
+		return testThinkTime.sample(user)
+	}
+	return 0
+}
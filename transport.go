@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// HTTP/2 modes of a test's transport.
+const (
+	HTTP2On  = "on"  // Negotiated with ALPN on https targets (default)
+	HTTP2Off = "off" // HTTP/1.1 only
+	HTTP2H2C = "h2c" // HTTP/2 over cleartext with prior knowledge, http targets only
+)
+
+const (
+	DefaultRequestTimeoutMs = 30000
+	MaxTransportTimeoutMs   = 120000 // Upper bound for every transport timeout
+)
+
+// TransportConfig controls how a test's requests reach the target. Timeouts
+// are in milliseconds; 0 means no limit beyond the request timeout.
+type TransportConfig struct {
+	DisableKeepAlive        bool   `json:"disable_keep_alive,omitempty"`      // Open a new connection for every request
+	MaxIdleConnsPerHost     int    `json:"max_idle_conns_per_host,omitempty"` // Defaults to the test's users
+	HTTP2                   string `json:"http2,omitempty"`                   // "on", "off" or "h2c"
+	RequestTimeoutMs        int    `json:"request_timeout_ms,omitempty"`      // Whole request including the body (default: 30000)
+	ConnectTimeoutMs        int    `json:"connect_timeout_ms,omitempty"`
+	TLSHandshakeTimeoutMs   int    `json:"tls_handshake_timeout_ms,omitempty"`
+	ResponseHeaderTimeoutMs int    `json:"response_header_timeout_ms,omitempty"`
+	InsecureSkipVerify      bool   `json:"insecure_skip_verify,omitempty"`
+	ServerName              string `json:"server_name,omitempty"` // SNI and certificate name, defaults to the target host
+}
+
+// validateTransportConfig checks the settings against the test's target URLs
+// and fills in defaults. users is the test's peak user or worker count.
+func validateTransportConfig(cfg *TransportConfig, targetURLs []string, users int) error {
+	if cfg.HTTP2 == "" {
+		cfg.HTTP2 = HTTP2On
+	}
+	if cfg.HTTP2 != HTTP2On && cfg.HTTP2 != HTTP2Off && cfg.HTTP2 != HTTP2H2C {
+		return fmt.Errorf("http2 must be one of %v", []string{HTTP2On, HTTP2Off, HTTP2H2C})
+	}
+
+	if cfg.RequestTimeoutMs == 0 {
+		cfg.RequestTimeoutMs = DefaultRequestTimeoutMs
+	}
+	timeouts := []struct {
+		name  string
+		value int
+	}{
+		{"request_timeout_ms", cfg.RequestTimeoutMs},
+		{"connect_timeout_ms", cfg.ConnectTimeoutMs},
+		{"tls_handshake_timeout_ms", cfg.TLSHandshakeTimeoutMs},
+		{"response_header_timeout_ms", cfg.ResponseHeaderTimeoutMs},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 || timeout.value > MaxTransportTimeoutMs {
+			return fmt.Errorf("%s must be between 0 and %d", timeout.name, MaxTransportTimeoutMs)
+		}
+	}
+
+	if cfg.MaxIdleConnsPerHost < 0 || cfg.MaxIdleConnsPerHost > MaxUsers {
+		return fmt.Errorf("max_idle_conns_per_host must be between 0 and %d", MaxUsers)
+	}
+	if cfg.MaxIdleConnsPerHost == 0 {
+		cfg.MaxIdleConnsPerHost = users
+	}
+
+	if len(cfg.ServerName) > 253 {
+		return fmt.Errorf("server_name is too long")
+	}
+
+	if cfg.HTTP2 == HTTP2H2C {
+		for _, targetURL := range targetURLs {
+			parsed, err := url.Parse(targetURL)
+			if err != nil {
+				return err
+			}
+			if parsed.Scheme != "http" {
+				return fmt.Errorf("h2c requires http:// targets")
+			}
+		}
+		// The HTTP/2 transport multiplexes one connection and has no
+		// response header timeout
+		if cfg.DisableKeepAlive {
+			return fmt.Errorf("disable_keep_alive is not supported with h2c")
+		}
+		if cfg.ResponseHeaderTimeoutMs > 0 {
+			return fmt.Errorf("response_header_timeout_ms is not supported with h2c")
+		}
+	}
+
+	return nil
+}
+
+// RequestTimeout is the client timeout for a whole request.
+func (cfg TransportConfig) RequestTimeout() time.Duration {
+	if cfg.RequestTimeoutMs <= 0 {
+		return DefaultRequestTimeoutMs * time.Millisecond
+	}
+	return time.Duration(cfg.RequestTimeoutMs) * time.Millisecond
+}
+
+// newTransport builds the round tripper shared by a test's users. creds is the
+// test's client certificate and CA bundle, nil without mutual TLS.
+func newTransport(cfg TransportConfig, creds *clientTLS) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(cfg.ConnectTimeoutMs) * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		ServerName:         cfg.ServerName,
+	}
+	creds.apply(tlsConfig)
+
+	if cfg.HTTP2 == HTTP2H2C {
+		return &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		DisableKeepAlives:     cfg.DisableKeepAlive,
+		MaxIdleConns:          0, // Bounded per host instead
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   time.Duration(cfg.TLSHandshakeTimeoutMs) * time.Millisecond,
+		ResponseHeaderTimeout: time.Duration(cfg.ResponseHeaderTimeoutMs) * time.Millisecond,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     cfg.HTTP2 == HTTP2On,
+	}
+	if cfg.HTTP2 == HTTP2Off {
+		// A non-nil empty map disables the transport's HTTP/2 upgrade
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	return transport
+}
+
+// closeIdleConnections releases the pooled connections of a finished test.
+func closeIdleConnections(rt http.RoundTripper) {
+	if closer, ok := rt.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
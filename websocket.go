@@ -0,0 +1,645 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DefaultWebSocketMessageRate = 1   // Messages per second per connection
+	MaxWebSocketMessageRate     = 100 // Per connection
+	MaxWebSocketMessages        = 20
+	MaxWebSocketMessageBytes    = 64 << 10
+
+	webSocketConnectStep    = "connect"
+	webSocketMessageStep    = "message" // Step of messages without a name
+	webSocketReconnectDelay = time.Second
+	webSocketReadLimit      = 1 << 20
+	webSocketSweepInterval  = 100 * time.Millisecond // How often unanswered messages are checked for timeouts
+)
+
+// ErrorCategoryDuplicateID fails a message still awaiting its reply when
+// another is sent with the same correlation id.
+const ErrorCategoryDuplicateID = "websocket_duplicate_id"
+
+// WebSocketConfig turns a test into a WebSocket test: every virtual user
+// holds a connection to the test's ws:// or wss:// host and sends the
+// messages in order, starting over after the last, at MessageRate.
+type WebSocketConfig struct {
+	Subprotocols    []string           `json:"subprotocols,omitempty"`
+	Messages        []WebSocketMessage `json:"messages,omitempty"`         // Without messages users only hold the connection and count what they receive
+	MessageRate     float64            `json:"message_rate,omitempty"`     // Messages per second per connection (default: 1)
+	CorrelationPath string             `json:"correlation_path,omitempty"` // JSONPath of the id shared by a message and its reply; empty matches the next message received
+	ReplyTimeoutMs  int                `json:"reply_timeout_ms,omitempty"` // Defaults to the transport's request timeout
+}
+
+// WebSocketMessage is a message template. Its round trips are reported as a
+// step named after it.
+type WebSocketMessage struct {
+	Name    string `json:"name,omitempty"`
+	Body    string `json:"body"`
+	Binary  bool   `json:"binary,omitempty"`
+	NoReply bool   `json:"no_reply,omitempty"` // Sent without waiting for a reply
+
+	body *requestTemplate
+}
+
+// WebSocketMetrics summarises the connections and messages of a WebSocket
+// test. Message round trips are also counted as the test's requests.
+type WebSocketMetrics struct {
+	Connections      int64        `json:"connections"`
+	ConnectFailures  int64        `json:"connect_failures"`
+	Disconnects      int64        `json:"disconnects"` // Connections closed by the target or the network during the test
+	MessagesSent     int64        `json:"messages_sent"`
+	MessagesReceived int64        `json:"messages_received"`
+	Unmatched        int64        `json:"unmatched"` // Received messages that answered no sent message
+	ReplyTimeouts    int64        `json:"reply_timeouts"`
+	ConnectLatency   LatencyStats `json:"connect_latency"`
+}
+
+// webSocketCollector accumulates WebSocket counters while a test runs.
+type webSocketCollector struct {
+	connectLatencies *hdrhistogram.Histogram
+	metrics          WebSocketMetrics
+}
+
+// isWebSocketURL reports whether a host is a ws:// or wss:// URL.
+func isWebSocketURL(rawURL string) bool {
+	lower := strings.ToLower(strings.TrimSpace(rawURL))
+	return strings.HasPrefix(lower, "ws://") || strings.HasPrefix(lower, "wss://")
+}
+
+// webSocketHTTPURL returns the http:// or https:// URL of a WebSocket target,
+// which the SSRF checks, cookie jars and signing work with. Other URLs are
+// returned unchanged.
+func webSocketHTTPURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	lower := strings.ToLower(rawURL)
+	switch {
+	case strings.HasPrefix(lower, "ws://"):
+		return "http://" + rawURL[len("ws://"):]
+	case strings.HasPrefix(lower, "wss://"):
+		return "https://" + rawURL[len("wss://"):]
+	}
+	return rawURL
+}
+
+// webSocketURL converts an http:// or https:// URL back to its WebSocket
+// scheme.
+func webSocketURL(httpURL string) string {
+	switch {
+	case strings.HasPrefix(httpURL, "http://"):
+		return "ws://" + httpURL[len("http://"):]
+	case strings.HasPrefix(httpURL, "https://"):
+		return "wss://" + httpURL[len("https://"):]
+	}
+	return httpURL
+}
+
+// validateWebSocketConfig checks a WebSocket test's settings, fills in
+// defaults and compiles the message templates.
+func validateWebSocketConfig(cfg *WebSocketConfig) error {
+	if cfg.MessageRate == 0 {
+		cfg.MessageRate = DefaultWebSocketMessageRate
+	}
+	if cfg.MessageRate < 0 || cfg.MessageRate > MaxWebSocketMessageRate {
+		return fmt.Errorf("message_rate must be greater than 0 and at most %d", MaxWebSocketMessageRate)
+	}
+	if len(cfg.Messages) > MaxWebSocketMessages {
+		return fmt.Errorf("at most %d messages are allowed", MaxWebSocketMessages)
+	}
+	if cfg.ReplyTimeoutMs < 0 || cfg.ReplyTimeoutMs > MaxTransportTimeoutMs {
+		return fmt.Errorf("reply_timeout_ms must be between 0 and %d", MaxTransportTimeoutMs)
+	}
+	if cfg.CorrelationPath != "" {
+		if _, err := parseJSONPath(cfg.CorrelationPath); err != nil {
+			return fmt.Errorf("correlation_path: %v", err)
+		}
+	}
+	for i := range cfg.Messages {
+		msg := &cfg.Messages[i]
+		if msg.Name == "" {
+			msg.Name = webSocketMessageStep
+		}
+		if msg.Name == webSocketConnectStep {
+			return fmt.Errorf("message %d: name %q is reserved", i+1, webSocketConnectStep)
+		}
+		if len(msg.Body) > MaxWebSocketMessageBytes {
+			return fmt.Errorf("message %q: body must be at most %d bytes", msg.Name, MaxWebSocketMessageBytes)
+		}
+		var err error
+		if msg.body, err = parseTemplate(msg.Body); err != nil {
+			return fmt.Errorf("message %q: %v", msg.Name, err)
+		}
+	}
+	return nil
+}
+
+// webSocketSteps returns the steps a WebSocket test reports: the connection
+// handshake to target, then one per message name.
+func webSocketSteps(cfg *WebSocketConfig, target string) []ScenarioStep {
+	steps := []ScenarioStep{{Name: webSocketConnectStep, URL: webSocketHTTPURL(target), Method: http.MethodGet}}
+	seen := map[string]bool{}
+	for _, msg := range cfg.Messages {
+		if !msg.NoReply && !seen[msg.Name] {
+			seen[msg.Name] = true
+			steps = append(steps, ScenarioStep{Name: msg.Name})
+		}
+	}
+	return steps
+}
+
+// replyTimeout is how long a message waits for its reply.
+func (cfg *WebSocketConfig) replyTimeout(transport TransportConfig) time.Duration {
+	if cfg.ReplyTimeoutMs > 0 {
+		return time.Duration(cfg.ReplyTimeoutMs) * time.Millisecond
+	}
+	return transport.RequestTimeout()
+}
+
+// newWebSocketDialer builds the dialer of one virtual user, sharing the
+// dialing, proxy and TLS settings of the test's transport.
+func newWebSocketDialer(testCtx *TestContext) *websocket.Dialer {
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: testCtx.TestRun.Transport.RequestTimeout(),
+		Subprotocols:     testCtx.WebSocket.Subprotocols,
+	}
+	if transport, ok := testCtx.Transport.(*http.Transport); ok {
+		dialer.NetDialContext = transport.DialContext
+		dialer.Proxy = transport.Proxy
+		if transport.TLSClientConfig != nil {
+			dialer.TLSClientConfig = transport.TLSClientConfig.Clone()
+			// The upgrade is an HTTP/1.1 request
+			dialer.TLSClientConfig.NextProtos = nil
+		}
+	}
+	if testCtx.Cookies != nil && testCtx.Cookies.Enabled {
+		dialer.Jar = newSessionJar(testCtx)
+	}
+	return dialer
+}
+
+// runWebSocketUser holds a virtual user's connection until the test stops or
+// the user retires, reconnecting after a second when the connection fails or
+// is closed.
+func (tm *TestManager) runWebSocketUser(testCtx *TestContext, stopChan <-chan struct{}, retire <-chan struct{}) {
+	ctx := testCtx.Context
+	dialer := newWebSocketDialer(testCtx)
+	user := newVirtualUser(testCtx, true)
+
+	for {
+		// Every connection is an iteration with its own data row
+		user.iteration++
+		if testCtx.Data != nil && !testCtx.Data.draw(user) {
+			return
+		}
+		if session := tm.dialWebSocket(ctx, dialer, testCtx, user); session != nil {
+			if stopped := session.run(ctx, stopChan, retire); stopped {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopChan:
+			return
+		case <-retire:
+			return
+		case <-time.After(webSocketReconnectDelay):
+		}
+	}
+}
+
+// dialWebSocket opens a user's connection, recording the handshake as a
+// request of the connect step. It returns nil when the handshake failed.
+func (tm *TestManager) dialWebSocket(ctx context.Context, dialer *websocket.Dialer, testCtx *TestContext, user *virtualUser) *webSocketSession {
+	metrics := testCtx.Metrics
+	step := &testCtx.Steps[0]
+	target := step.url.render(user)
+	start := time.Now()
+
+	var conn *websocket.Conn
+	var resp *http.Response
+	var errorCategory string
+	req, err := webSocketRequest(ctx, testCtx, user, target)
+	if err != nil {
+		errorCategory = ErrorCategoryRequestError
+	} else if tokens := testCtx.tokenSourceFor(user); tokens != nil {
+		var token string
+		if token, err = tokens.Token(ctx); err != nil {
+			errorCategory = ErrorCategoryTokenError
+			if ctx.Err() != nil {
+				errorCategory = classifyError(err)
+			}
+		} else {
+			req.Header.Set("Authorization", "Bearer "+token)
+			// Token requests have their own metrics
+			start = time.Now()
+		}
+	}
+	if err == nil {
+		signRequest(req, testCtx.AuthConfig, time.Now())
+		// The dialer sets the upgrade headers itself
+		for _, key := range []string{"Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions", "Sec-Websocket-Protocol"} {
+			req.Header.Del(key)
+		}
+		conn, resp, err = dialer.DialContext(ctx, webSocketURL(target), req.Header)
+	}
+	completedAt := time.Now()
+	latency := completedAt.Sub(start).Seconds() * 1000
+
+	statusCode := 0
+	if resp != nil {
+		statusCode = resp.StatusCode
+		resp.Body.Close()
+		if statusCode == http.StatusUnauthorized {
+			// A rejected token is fetched again on the next connection
+			if tokens := testCtx.tokenSourceFor(user); tokens != nil {
+				tokens.invalidate(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+			}
+		}
+	}
+	success := err == nil
+	switch {
+	case success, errorCategory != "":
+	case errors.Is(err, websocket.ErrBadHandshake) && statusCode >= 400:
+		errorCategory = statusErrorCategory(statusCode)
+		err = fmt.Errorf("HTTP %s", resp.Status)
+	case errors.Is(err, websocket.ErrBadHandshake):
+		errorCategory = ErrorCategoryWebSocket
+	default:
+		errorCategory = classifyError(err)
+	}
+
+	metrics.Record(latency, latency, success, statusCode)
+	metrics.RecordStep(step.Name, latency, success)
+	metrics.recordWebSocket(func(c *webSocketCollector) {
+		if success {
+			c.metrics.Connections++
+			recordLatency(c.connectLatencies, latency)
+		} else {
+			c.metrics.ConnectFailures++
+		}
+	})
+	if !success {
+		metrics.RecordError(errorCategory, statusCode, errorSample(err))
+	}
+	testCtx.Writer.Write(&RequestMetric{
+		TestRunID:        testCtx.TestRun.ID,
+		Timestamp:        completedAt,
+		Latency:          latency,
+		CorrectedLatency: latency,
+		Success:          success,
+		StatusCode:       statusCode,
+		ErrorCategory:    errorCategory,
+	})
+	if !success {
+		return nil
+	}
+
+	if tlsConn, ok := conn.UnderlyingConn().(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		metrics.RecordTLSSession(state.Version, state.CipherSuite)
+	}
+	conn.SetReadLimit(webSocketReadLimit)
+	session := &webSocketSession{testCtx: testCtx, conn: conn, user: user}
+	if testCtx.WebSocket.CorrelationPath != "" {
+		session.correlation, _ = parseJSONPath(testCtx.WebSocket.CorrelationPath)
+		session.byID = make(map[string]webSocketPending)
+	}
+	return session
+}
+
+// webSocketRequest builds the handshake request whose headers the dialer
+// sends: the test's headers and static authentication, like executeRequest.
+func webSocketRequest(ctx context.Context, testCtx *TestContext, user *virtualUser, target string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range testCtx.Headers {
+		req.Header.Set(key, value.render(user))
+	}
+	applyAuth(req, testCtx.AuthConfig)
+	return req, nil
+}
+
+// webSocketSession is one open connection of a virtual user and the messages
+// it is waiting for replies to.
+type webSocketSession struct {
+	testCtx     *TestContext
+	conn        *websocket.Conn
+	user        *virtualUser
+	correlation []jsonPathSegment // Nil matches replies in order
+
+	mu    sync.Mutex
+	queue []webSocketPending          // Unanswered messages in the order they were sent
+	byID  map[string]webSocketPending // Unanswered messages by correlation id
+}
+
+type webSocketPending struct {
+	step           string
+	sent, intended time.Time
+}
+
+// run sends the scripted messages until the test stops, the user retires or
+// the connection is lost. It reports whether the user should stop.
+func (s *webSocketSession) run(ctx context.Context, stopChan, retire <-chan struct{}) bool {
+	cfg := s.testCtx.WebSocket
+	readDone := make(chan error, 1)
+	go func() { readDone <- s.readLoop() }()
+
+	// Messages keep a fixed schedule like closed-loop iterations, so a
+	// stalled connection sends its missed messages late rather than never
+	var send <-chan time.Time
+	interval := time.Duration(float64(time.Second) / cfg.MessageRate)
+	intended := time.Now().Add(interval)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	if len(cfg.Messages) > 0 {
+		send = timer.C
+	}
+	sweep := time.NewTicker(webSocketSweepInterval)
+	defer sweep.Stop()
+	timeout := cfg.replyTimeout(s.testCtx.TestRun.Transport)
+
+	next := 0
+	for {
+		select {
+		case <-ctx.Done():
+			s.close(readDone)
+			return true
+		case <-stopChan:
+			s.close(readDone)
+			return true
+		case <-retire:
+			s.close(readDone)
+			return true
+		case err := <-readDone:
+			// Closed by the target or the network; unanswered messages fail
+			s.conn.Close()
+			s.testCtx.Metrics.recordWebSocket(func(c *webSocketCollector) { c.metrics.Disconnects++ })
+			s.failPending(classifyWebSocketError(err), errorSample(err))
+			return false
+		case <-send:
+			msg := &cfg.Messages[next]
+			next = (next + 1) % len(cfg.Messages)
+			if err := s.send(msg, intended); err != nil {
+				// A failed write breaks the connection; the reader reports it
+				s.conn.Close()
+			}
+			intended = intended.Add(interval)
+			timer.Reset(time.Until(intended))
+		case <-sweep.C:
+			s.expire(timeout)
+		}
+	}
+}
+
+// send writes one message, tracking it for its reply first so a fast reply
+// cannot arrive before it is expected.
+func (s *webSocketSession) send(msg *WebSocketMessage, intended time.Time) error {
+	payload := msg.body.render(s.user)
+	sent := time.Now()
+	if intended.After(sent) {
+		intended = sent
+	}
+
+	id := ""
+	if !msg.NoReply {
+		if s.correlation != nil {
+			var ok bool
+			if id, ok = correlationID([]byte(payload), s.correlation); !ok {
+				s.recordMessage(msg.Name, 0, 0, false, ErrorCategoryRequestError,
+					fmt.Sprintf("message has no value at %s", s.testCtx.WebSocket.CorrelationPath), sent)
+				return nil
+			}
+		}
+		if replaced, ok := s.track(id, webSocketPending{step: msg.Name, sent: sent, intended: intended}); ok {
+			// Its reply can no longer be told apart from the new message's
+			s.recordMessage(replaced.step, sent.Sub(replaced.sent).Seconds()*1000, sent.Sub(replaced.intended).Seconds()*1000,
+				false, ErrorCategoryDuplicateID, fmt.Sprintf("correlation id %q was sent again before its reply", id), sent)
+		}
+	}
+
+	messageType := websocket.TextMessage
+	if msg.Binary {
+		messageType = websocket.BinaryMessage
+	}
+	s.conn.SetWriteDeadline(sent.Add(s.testCtx.TestRun.Transport.RequestTimeout()))
+	if err := s.conn.WriteMessage(messageType, []byte(payload)); err != nil {
+		if !msg.NoReply {
+			s.untrack(id)
+			latency := time.Since(sent).Seconds() * 1000
+			s.recordMessage(msg.Name, latency, time.Since(intended).Seconds()*1000, false, classifyWebSocketError(err), errorSample(err), time.Now())
+		}
+		return err
+	}
+	s.testCtx.Metrics.recordWebSocket(func(c *webSocketCollector) { c.metrics.MessagesSent++ })
+	return nil
+}
+
+// readLoop receives messages until the connection fails, matching each to
+// the message it answers.
+func (s *webSocketSession) readLoop() error {
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		received := time.Now()
+		pending, ok := s.match(data)
+		s.testCtx.Metrics.recordWebSocket(func(c *webSocketCollector) {
+			c.metrics.MessagesReceived++
+			if !ok {
+				c.metrics.Unmatched++
+			}
+		})
+		if ok {
+			s.recordMessage(pending.step, received.Sub(pending.sent).Seconds()*1000,
+				received.Sub(pending.intended).Seconds()*1000, true, "", "", received)
+		}
+	}
+}
+
+// close sends a close frame, waits for the reader to stop and drops the
+// unanswered messages, which the end of the test cut short.
+func (s *webSocketSession) close(readDone <-chan error) {
+	deadline := time.Now().Add(time.Second)
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+	s.conn.Close()
+	<-readDone
+}
+
+// track adds a sent message to those awaiting a reply. A message with the
+// correlation id of one still waiting replaces it, and the replaced message
+// is returned.
+func (s *webSocketSession) track(id string, pending webSocketPending) (webSocketPending, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byID != nil {
+		replaced, ok := s.byID[id]
+		s.byID[id] = pending
+		return replaced, ok
+	}
+	s.queue = append(s.queue, pending)
+	return webSocketPending{}, false
+}
+
+func (s *webSocketSession) untrack(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byID != nil {
+		delete(s.byID, id)
+		return
+	}
+	if len(s.queue) > 0 {
+		s.queue = s.queue[:len(s.queue)-1]
+	}
+}
+
+// match returns the unanswered message a received one replies to: the one
+// with the same correlation id, or else the oldest.
+func (s *webSocketSession) match(data []byte) (webSocketPending, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byID != nil {
+		id, ok := correlationID(data, s.correlation)
+		if !ok {
+			return webSocketPending{}, false
+		}
+		pending, ok := s.byID[id]
+		delete(s.byID, id)
+		return pending, ok
+	}
+	if len(s.queue) == 0 {
+		return webSocketPending{}, false
+	}
+	pending := s.queue[0]
+	s.queue = s.queue[1:]
+	return pending, true
+}
+
+// expire fails the messages that have waited longer than timeout.
+func (s *webSocketSession) expire(timeout time.Duration) {
+	now := time.Now()
+	var expired []webSocketPending
+	s.mu.Lock()
+	if s.byID != nil {
+		for id, pending := range s.byID {
+			if now.Sub(pending.sent) > timeout {
+				expired = append(expired, pending)
+				delete(s.byID, id)
+			}
+		}
+	} else {
+		for len(s.queue) > 0 && now.Sub(s.queue[0].sent) > timeout {
+			expired = append(expired, s.queue[0])
+			s.queue = s.queue[1:]
+		}
+	}
+	s.mu.Unlock()
+
+	for _, pending := range expired {
+		s.testCtx.Metrics.recordWebSocket(func(c *webSocketCollector) { c.metrics.ReplyTimeouts++ })
+		s.recordMessage(pending.step, now.Sub(pending.sent).Seconds()*1000, now.Sub(pending.intended).Seconds()*1000,
+			false, ErrorCategoryTimeout, fmt.Sprintf("no reply within %v", timeout), now)
+	}
+}
+
+// failPending fails every unanswered message of a lost connection.
+func (s *webSocketSession) failPending(category, message string) {
+	now := time.Now()
+	s.mu.Lock()
+	pending := s.queue
+	for _, p := range s.byID {
+		pending = append(pending, p)
+	}
+	s.queue, s.byID = nil, nil
+	s.mu.Unlock()
+
+	for _, p := range pending {
+		s.recordMessage(p.step, now.Sub(p.sent).Seconds()*1000, now.Sub(p.intended).Seconds()*1000, false, category, message, now)
+	}
+}
+
+// recordMessage records a message round trip as a request of its step.
+func (s *webSocketSession) recordMessage(step string, latency, correctedLatency float64, success bool, category, message string, at time.Time) {
+	metrics := s.testCtx.Metrics
+	metrics.RecordWithoutStatus(latency, correctedLatency, success)
+	metrics.RecordStep(step, latency, success)
+	if !success {
+		metrics.RecordError(category, 0, message)
+	}
+	s.testCtx.Writer.Write(&RequestMetric{
+		TestRunID:        s.testCtx.TestRun.ID,
+		Timestamp:        at,
+		Latency:          latency,
+		CorrectedLatency: correctedLatency,
+		Success:          success,
+		ErrorCategory:    category,
+	})
+}
+
+// correlationID reads the id at path from a JSON message.
+func correlationID(data []byte, path []jsonPathSegment) (string, bool) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", false
+	}
+	value, ok := lookupJSONPath(doc, path)
+	if !ok {
+		return "", false
+	}
+	return jsonValueString(value), true
+}
+
+// classifyWebSocketError maps a connection error to an error category. Close
+// frames from the target and protocol violations are WebSocket errors.
+func classifyWebSocketError(err error) string {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) || errors.Is(err, websocket.ErrReadLimit) {
+		return ErrorCategoryWebSocket
+	}
+	return classifyError(err)
+}
+
+// recordWebSocket updates the test's WebSocket counters under the collector
+// lock.
+func (mc *MetricsCollector) recordWebSocket(update func(c *webSocketCollector)) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.websocket == nil {
+		mc.websocket = &webSocketCollector{connectLatencies: newLatencyHistogram(mc.precision)}
+	}
+	update(mc.websocket)
+}
+
+// WebSocketMetrics summarises the connections and messages so far, or returns
+// nil for tests without WebSocket connections.
+func (mc *MetricsCollector) WebSocketMetrics() *WebSocketMetrics {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	if mc.websocket == nil {
+		return nil
+	}
+	metrics := mc.websocket.metrics
+	metrics.ConnectLatency = histogramStats(mc.websocket.connectLatencies)
+	return &metrics
+}